@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/services"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// runBackfill rebuilds past profits into the ledger without posting every transaction
+func runBackfill(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("backfill")
+	since := fs.String("since", "", "first day to rebuild, YYYY-MM-DD (required)")
+	postSummary := fs.Bool("post-summary", false, "post one summary message per account to Telegram")
+	dryRun := fs.Bool("dry-run", false, "compute and print the summary without writing the ledger")
+	fs.Parse(args)
+
+	if *since == "" {
+		return fmt.Errorf("--since is required")
+	}
+	sinceTime, err := time.Parse("2006-01-02", *since)
+	if err != nil {
+		return fmt.Errorf("invalid --since: %v", err)
+	}
+
+	config, err := loadConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	ledger, err := openLedger(config)
+	if err != nil {
+		return err
+	}
+	defer ledger.Close()
+
+	for _, cfg := range config.Accounts {
+		cfg.DryRun = *dryRun
+		summary, err := services.Backfill(ctx, cfg, sinceTime.Unix(), ledger)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", cfg.Label, err)
+		}
+
+		text := services.FormatBackfillSummary(summary)
+		fmt.Println(text)
+		fmt.Println()
+
+		if *postSummary && !*dryRun && cfg.TelegramToken != "" {
+			bot, err := tgbotapi.NewBotAPI(cfg.TelegramToken)
+			if err != nil {
+				slog.Error("Telegram send failed", "account", cfg.Label, "error", err)
+				continue
+			}
+			if _, err := bot.Send(tgbotapi.NewMessageToChannel(cfg.TelegramChatID, text)); err != nil {
+				slog.Error("Telegram send failed", "account", cfg.Label, "error", err)
+			}
+		}
+	}
+
+	return nil
+}
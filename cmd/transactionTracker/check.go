@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/services"
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+)
+
+// runCheck verifies every credential with one real call each
+func runCheck(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("check")
+	fs.Parse(args)
+
+	config, err := loadConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	var results []types.CheckResult
+	for _, cfg := range config.Accounts {
+		results = append(results, services.CheckAccount(ctx, cfg))
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	fmt.Println(services.FormatCheckTable(results))
+
+	for _, r := range results {
+		if !r.OK {
+			return errors.New("some checks failed")
+		}
+	}
+	return nil
+}
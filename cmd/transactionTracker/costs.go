@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/services"
+)
+
+// runCosts loads the cost basis exactly like "run" does and prints it
+func runCosts(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("costs")
+	fs.Parse(args)
+
+	config, err := loadConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	manualCosts, err := openManualCosts(config)
+	if err != nil {
+		return err
+	}
+
+	costMap, costMu := services.InitCostBasis(ctx, config.Accounts)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	manualCosts.Apply(costMap, costMu)
+
+	costMu.RLock()
+	defer costMu.RUnlock()
+
+	ids := make([]string, 0, len(costMap))
+	for id := range costMap {
+		ids = append(ids, id)
+	}
+	// Oldest buys first
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := costMap[ids[i]], costMap[ids[j]]
+		if a.AcquiredAt != b.AcquiredAt {
+			return a.AcquiredAt < b.AcquiredAt
+		}
+		return ids[i] < ids[j]
+	})
+
+	fmt.Println()
+	for _, id := range ids {
+		entry := costMap[id]
+		acquired := "unknown"
+		if entry.AcquiredAt > 0 {
+			acquired = time.Unix(entry.AcquiredAt, 0).UTC().Format("2006-01-02 15:04")
+		}
+		if entry.Manual {
+			acquired += " (marked)"
+		}
+		fmt.Printf("%-40s %12s %s  %s\n", id, entry.Price.String(), entry.Price.CurrencyCode(), acquired)
+	}
+	fmt.Printf("\n%d items\n", len(ids))
+	return nil
+}
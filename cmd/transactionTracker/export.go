@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/services"
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+)
+
+// runExport writes the joined trade ledger of all (or one) accounts to a file
+func runExport(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("export")
+	from := fs.String("from", "", "first day to include, YYYY-MM-DD")
+	to := fs.String("to", "", "last day to include, YYYY-MM-DD")
+	format := fs.String("format", "csv", "csv, excel (CSV with BOM) or json")
+	out := fs.String("out", "", "output file (default export.<format>)")
+	fs.Parse(args)
+
+	config, err := loadConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	// 1. Date range
+	var fromTime, toTime time.Time
+	if *from != "" {
+		if fromTime, err = time.Parse("2006-01-02", *from); err != nil {
+			return fmt.Errorf("invalid --from: %v", err)
+		}
+	}
+	if *to != "" {
+		if toTime, err = time.Parse("2006-01-02", *to); err != nil {
+			return fmt.Errorf("invalid --to: %v", err)
+		}
+		toTime = toTime.AddDate(0, 0, 1) // Inclusive
+	}
+
+	if *format != "csv" && *format != "excel" && *format != "json" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	// 2. Collect rows (the whole history is needed to join buys made before --from)
+	var rows []types.ExportRow
+	for _, cfg := range config.Accounts {
+		accountRows, err := services.ExportAccountLedger(ctx, cfg)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", cfg.Label, err)
+		}
+		rows = append(rows, services.FilterExportRows(accountRows, fromTime, toTime)...)
+	}
+
+	// 3. Write
+	path := *out
+	if path == "" {
+		ext := *format
+		if ext == "excel" {
+			ext = "csv"
+		}
+		path = "export." + ext
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if *format == "json" {
+		err = services.WriteExportJSON(file, rows)
+	} else {
+		err = services.WriteExportCSV(file, rows, *format == "excel")
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Exported %d rows to %s\n", len(rows), path)
+	return nil
+}
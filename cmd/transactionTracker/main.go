@@ -1,43 +1,181 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"sync"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/cyberbebebe/dmarket-transactions-poster/services"
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+	"golang.org/x/term"
 )
 
+const usage = `Usage: DmarketTracker [command] [flags]
+
+Commands:
+  run        Track accounts and post transactions to Telegram (default)
+  check      Verify every key (DMarket, CSFloat, Telegram) and print a pass/fail table
+  report     Print a report: "report inventory" (default), "report holding" or "report pending"
+  costs      Print the loaded cost basis (buy price and date per item)
+  export     Write the full trade ledger as CSV/JSON
+  backfill   Rebuild profits for past sales into the ledger
+
+Common flags:
+  --config PATH     Config file (default: config/config.{yaml,yml,toml,json})
+  --account LABEL   Only use this account
+  --headless        Never wait for [ENTER] on errors (default when stdin is not a terminal)
+  --log-level LEVEL debug, info, warn or error (overrides config log_level)
+  --log-format FMT  text or json (overrides config log_format)
+
+Run "DmarketTracker <command> -h" for command flags.
+`
+
+// options are the flags shared by every command
+type options struct {
+	configPath string
+	account    string
+	logLevel   string
+	logFormat  string
+}
+
+// headless is set by --headless, the pause on errors is skipped
+var headless bool
+
 func main() {
-	// 1. Load Config
-	configs, err := services.LoadConfig("config/config.json")
+	// 1. Pick the command ("run" when omitted, so the plain binary keeps working)
+	command := "run"
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command = args[0]
+		args = args[1:]
+	}
+
+	// 2. Text logs until the config says otherwise
+	services.SetupLogging("info", "text")
+
+	// 3. SIGINT/SIGTERM cancel the context, every command winds down from there
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 4. Dispatch
+	var err error
+	switch command {
+	case "run":
+		err = runTracker(ctx, args)
+	case "check":
+		err = runCheck(ctx, args)
+	case "report":
+		err = runReport(ctx, args)
+	case "costs":
+		err = runCosts(ctx, args)
+	case "export":
+		err = runExport(ctx, args)
+	case "backfill":
+		err = runBackfill(ctx, args)
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Printf("Unknown command: %s\n\n%s", command, usage)
+		os.Exit(2)
+	}
+
+	if errors.Is(err, context.Canceled) {
+		stop()
+		slog.Info("Interrupted", "command", command)
+		os.Exit(130)
+	}
 	if err != nil {
-		panic(err)
+		stop()
+		slog.Error("Command failed", "command", command, "error", err)
+
+		// Keep a double-clicked window open long enough to read the error
+		if command == "run" && interactive() {
+			fmt.Println("\nPress [ENTER] to exit program...")
+			var input string
+			fmt.Scanln(&input)
+		}
+		os.Exit(1)
 	}
+}
 
-	// 2. Prepare Data (The Brain)
-	costMap, costMu := services.InitCostBasis(configs)
+// interactive is true when someone can press [ENTER]: stdin is a terminal and --headless wasn't given.
+// Under systemd, Docker (without -t) or a pipe stdin is not a terminal.
+func interactive() bool {
+	if headless {
+		return false
+	}
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
 
-	// 3. Wake up telegram bots
-	botMap, err := services.WakeUpBots(configs)
-	if err != nil { panic(err) }
-	
-	// 4. Start Workers
-	var wg sync.WaitGroup
+// newFlagSet creates a command's flag set with the common flags registered
+func newFlagSet(name string) (*flag.FlagSet, *options) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	opts := &options{}
+	fs.StringVar(&opts.configPath, "config", "", "config file (default: config/config.{yaml,yml,toml,json})")
+	fs.StringVar(&opts.account, "account", "", "only use this account label")
+	fs.BoolVar(&headless, "headless", false, "never wait for [ENTER] on errors")
+	fs.StringVar(&opts.logLevel, "log-level", "", "debug, info, warn or error (overrides config)")
+	fs.StringVar(&opts.logFormat, "log-format", "", "text or json (overrides config)")
+	return fs, opts
+}
 
-	fmt.Println("Launching Workers...")
+// loadConfig reads the config and keeps only the selected account (if any)
+func loadConfig(opts *options) (types.Config, error) {
+	path := opts.configPath
+	if path == "" {
+		found, err := services.FindConfig()
+		if err != nil {
+			return types.Config{}, err
+		}
+		path = found
+	}
 
-	for _, cfg := range configs {
-		wg.Add(1)
-		// Launch a Tracker for each account
-		botInstance := botMap[cfg.TelegramToken]
-		
-		go services.StartTracker(cfg, botInstance, costMap, costMu, &wg)
+	config, err := services.LoadConfig(path)
+	if err != nil {
+		return config, err
+	}
 
-		if cfg.CSFloatKey != "" {
-			wg.Add(1)
-			go services.StartCSFloatPoller(cfg, costMap, costMu, &wg)
+	// Logging follows the config, keys and tokens never reach the log
+	if opts.logLevel != "" {
+		config.LogLevel = opts.logLevel
+	}
+	if opts.logFormat != "" {
+		config.LogFormat = opts.logFormat
+	}
+	if err := services.SetupLogging(config.LogLevel, config.LogFormat); err != nil {
+		return config, err
+	}
+	services.RedactSecrets(config)
+
+	if opts.account != "" {
+		var selected []types.AccountConfig
+		for _, cfg := range config.Accounts {
+			if cfg.Label == opts.account {
+				selected = append(selected, cfg)
+			}
 		}
+		if len(selected) == 0 {
+			return config, fmt.Errorf("no account labelled %q in %s", opts.account, path)
+		}
+		config.Accounts = selected
 	}
 
-	wg.Wait()
-}
\ No newline at end of file
+	return config, nil
+}
+
+// openLedger opens the ledger inside the configured storage path
+func openLedger(config types.Config) (*services.Ledger, error) {
+	return services.OpenLedger(filepath.Join(config.StoragePath, "ledger.jsonl"))
+}
+
+// openManualCosts opens the buy prices marked by hand inside the configured storage path
+func openManualCosts(config types.Config) (*services.ManualCosts, error) {
+	return services.OpenManualCosts(filepath.Join(config.StoragePath, "manual_costs.json"))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/services"
+)
+
+// runReport prints the inventory (unrealized P&L), holding-period or pending-release report
+func runReport(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("report")
+	fs.Usage = func() {
+		fmt.Println("Usage: DmarketTracker report [inventory|holding|pending] [flags]")
+		fs.PrintDefaults()
+	}
+
+	// Report kind comes first: "report holding --account X"
+	kind := "inventory"
+	if len(args) > 0 && (args[0] == "inventory" || args[0] == "holding" || args[0] == "pending") {
+		kind = args[0]
+		args = args[1:]
+	}
+	fs.Parse(args)
+
+	config, err := loadConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	if kind == "holding" || kind == "pending" {
+		ledger, err := openLedger(config)
+		if err != nil {
+			return err
+		}
+		defer ledger.Close()
+
+		for _, cfg := range config.Accounts {
+			if kind == "pending" {
+				fmt.Println(services.FormatPendingReleases(cfg, services.PendingReleases(ledger.Entries(cfg.Label)), time.Now()))
+			} else {
+				fmt.Println(services.FormatHoldingReport(cfg, ledger.Entries(cfg.Label)))
+			}
+			fmt.Println()
+		}
+		return nil
+	}
+
+	manualCosts, err := openManualCosts(config)
+	if err != nil {
+		return err
+	}
+	costMap, costMu := services.InitCostBasis(ctx, config.Accounts)
+	manualCosts.Apply(costMap, costMu)
+	for _, cfg := range config.Accounts {
+		report, err := services.BuildInventoryReport(ctx, cfg, costMap, costMu)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			slog.Error("Inventory report failed", "account", cfg.Label, "error", err)
+			continue
+		}
+		fmt.Println(services.FormatInventoryReport(report))
+		fmt.Println()
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/services"
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// How long shutdown waits for queued Telegram messages
+const flushTimeout = 30 * time.Second
+
+// runTracker starts the trackers, CSFloat pollers and bot command listeners.
+// It runs until ctx is cancelled (SIGINT/SIGTERM), then drains the outbox and saves state.
+func runTracker(ctx context.Context, args []string) error {
+	fs, opts := newFlagSet("run")
+	interval := fs.Duration("interval", 0, "poll interval, e.g. 15s or 5m (overrides config)")
+	limit := fs.Int("limit", 0, "transactions requested per poll (overrides config)")
+	dryRun := fs.Bool("dry-run", false, "print messages instead of sending them, don't write the ledger")
+	httpAddr := fs.String("http-addr", "", "serve /metrics on this address, e.g. :9090 (overrides config)")
+	dashboardAddr := fs.String("dashboard-addr", "", "serve the web dashboard on this address, e.g. 127.0.0.1:8080 (overrides config)")
+	keepGoing := fs.Bool("keep-going", false, "start the other accounts when a Telegram bot fails (the broken account runs degraded)")
+	fs.Parse(args)
+
+	// 1. Load Config (CLI flags override it, also on every reload)
+	load := func() (types.Config, error) {
+		config, err := loadConfig(opts)
+		if err != nil {
+			return config, err
+		}
+		for i := range config.Accounts {
+			if *interval > 0 {
+				config.Accounts[i].PollInterval = types.Duration(*interval)
+			}
+			if *limit > 0 {
+				config.Accounts[i].HistoryLimit = *limit
+			}
+			config.Accounts[i].DryRun = *dryRun
+		}
+		return config, nil
+	}
+
+	config, err := load()
+	if err != nil {
+		return err
+	}
+	configs := config.Accounts
+
+	// 2. Prepare Data (The Brain)
+	ledger, err := openLedger(config)
+	if err != nil {
+		return err
+	}
+	defer ledger.Close()
+
+	state, err := services.OpenState(filepath.Join(config.StoragePath, "state.json"))
+	if err != nil {
+		return err
+	}
+
+	balances, err := services.OpenBalances(filepath.Join(config.StoragePath, "balances.jsonl"))
+	if err != nil {
+		return err
+	}
+	defer balances.Close()
+
+	manualCosts, err := openManualCosts(config)
+	if err != nil {
+		return err
+	}
+
+	costMap, costMu := services.InitCostBasis(ctx, configs)
+	if ctx.Err() != nil {
+		// Interrupted while loading, nothing started yet
+		return ctx.Err()
+	}
+	manualCosts.Apply(costMap, costMu)
+
+	// 3. Wake up telegram bots (not needed when only printing)
+	botMap := make(map[string]*tgbotapi.BotAPI)
+	if !*dryRun {
+		var failed map[string]error
+		botMap, failed = services.WakeUpBots(configs)
+		if len(failed) > 0 && !*keepGoing {
+			return fmt.Errorf("%d account(s) without a working Telegram bot (use --keep-going to start the others)", len(failed))
+		}
+		for label, err := range failed {
+			slog.Warn("Starting degraded, messages are only logged until the bot works", "account", label, "error", err)
+		}
+	}
+
+	// 4. Start Workers (trackers, balance and CSFloat pollers, one command listener per bot)
+	var wg sync.WaitGroup
+	shared := &services.Shared{
+		Costs:       costMap,
+		CostMu:      costMu,
+		Ledger:      ledger,
+		Bots:        services.NewBotRegistry(botMap),
+		Outbox:      services.NewOutbox(100),
+		State:       state,
+		Health:      services.NewHealth(),
+		Balances:    balances,
+		ManualCosts: manualCosts,
+	}
+
+	slog.Info("Launching workers", "accounts", len(configs))
+
+	supervisor := services.NewSupervisor(shared, &wg)
+	supervisor.Apply(ctx, configs)
+
+	// Staleness alerts to the owner/account chats
+	wg.Add(1)
+	go services.StartHealthMonitor(ctx, supervisor.Accounts, shared, &wg)
+
+	// 5. Metrics, health, REST API and dashboard endpoints (read once, changes need a restart)
+	if *httpAddr != "" {
+		config.HTTPAddr = *httpAddr
+	}
+	if config.HTTPAddr != "" {
+		services.RegisterCostBasisMetric(costMap, costMu)
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		mux.Handle("/healthz", shared.Health.HealthHandler())
+		mux.Handle("/readyz", shared.Health.ReadyHandler())
+		if len(config.APITokens) > 0 {
+			// REST API for other bots, every other path needs a token
+			mux.Handle("/", services.APIHandler(config.APITokens, supervisor.Accounts, shared))
+		}
+
+		wg.Add(1)
+		go services.ServeHTTP(ctx, config.HTTPAddr, mux, &wg)
+	} else if len(config.APITokens) > 0 {
+		slog.Warn("api_tokens is set but http_addr is empty, the REST API is off")
+	}
+
+	// Web dashboard (own address, so it can stay on localhost while /metrics is scraped)
+	if *dashboardAddr != "" {
+		config.DashboardAddr = *dashboardAddr
+	}
+	if config.DashboardAddr != "" {
+		wg.Add(1)
+		go services.ServeHTTP(ctx, config.DashboardAddr, services.DashboardHandler(supervisor.Accounts, shared), &wg)
+	}
+
+	// 6. Hot reload: config file changes and SIGHUP add/remove/update accounts
+	configPath := opts.configPath
+	if configPath == "" {
+		configPath, _ = services.FindConfig()
+	}
+	go services.WatchConfig(ctx, configPath,
+		func() ([]types.AccountConfig, error) {
+			config, err := load()
+			return config.Accounts, err
+		},
+		func(configs []types.AccountConfig) { supervisor.Apply(ctx, configs) },
+	)
+
+	// 7. Shutdown: workers stop on cancel, then queued messages and state are flushed
+	<-ctx.Done()
+	signal.Reset(os.Interrupt, syscall.SIGTERM)
+	slog.Info("Shutting down, waiting for workers (press Ctrl+C again to force)")
+	wg.Wait()
+
+	var errs []error
+	if err := shared.Outbox.Flush(flushTimeout); err != nil {
+		errs = append(errs, fmt.Errorf("outbox: %v", err))
+	}
+	if !*dryRun {
+		if err := state.Save(); err != nil {
+			errs = append(errs, fmt.Errorf("state: %v", err))
+		}
+	}
+	if err := ledger.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("ledger: %v", err))
+	}
+	if err := balances.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("balances: %v", err))
+	}
+
+	slog.Info("Stopped")
+	return errors.Join(errs...)
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Ledger flows: trades are P&L, capital moves cash in or out, item transfers move items to/from Steam
+const (
+	FlowTrade   = ""
+	FlowCapital = "capital"
+	FlowItem    = "item"
+)
+
+// Message headline per non-trade activity
+var activityTitles = map[string]string{
+	"deposit":       "Deposit",
+	"withdraw":      "Withdrawal",
+	"refund":        "Refund",
+	"item_deposit":  "Item deposit",
+	"item_withdraw": "Item withdrawal",
+}
+
+// ActivityFlow classifies a history transaction by its activity type
+func ActivityFlow(tx types.Transaction) string {
+	switch tx.Type {
+	case "deposit", "withdraw", "refund":
+		return FlowCapital
+	case "item_deposit", "item_withdraw":
+		return FlowItem
+	}
+	return FlowTrade
+}
+
+// capitalChange is the cash a capital transaction moves: deposits and refunds in, withdrawals out
+func capitalChange(tx types.Transaction) types.Money {
+	if ActivityFlow(tx) != FlowCapital || len(tx.Changes) == 0 {
+		return types.Money{}
+	}
+	amount := tx.Changes[0].Money.Abs()
+	if tx.Type == "withdraw" {
+		return amount.Neg()
+	}
+	return amount
+}
+
+// PostMovement queues a deposit, withdrawal, refund or item transfer formatted by FormatMovement on the outbox.
+// These are never trades, so there is no fee or profit.
+func PostMovement(ctx context.Context, outbox *Outbox, bot *tgbotapi.BotAPI, tx types.Transaction, cfg types.AccountConfig, message string) {
+	if ActivityFlow(tx) == FlowItem {
+		deliverItemMessage(ctx, outbox, bot, cfg, tx, message)
+		return
+	}
+	deliverMessage(outbox, bot, cfg, message)
+}
+
+// FormatMovement renders "Deposit success", the subject (item or refund reason) and the money block
+func FormatMovement(ctx context.Context, tx types.Transaction, cfg types.AccountConfig, liveBalance types.UserBalanceResponse) string {
+	title, ok := activityTitles[tx.Type]
+	if !ok {
+		title = tx.Type
+	}
+
+	var message strings.Builder
+	message.WriteString(fmt.Sprintf("%s %s", title, fixMarkdownV2(tx.Status)))
+	if tx.Subject != "" {
+		message.WriteString(fmt.Sprintf("\n`%s`%s", tx.Subject, itemDetails(ctx, tx, cfg)))
+	}
+
+	var moneyData strings.Builder
+	if change := capitalChange(tx); !change.IsZero() {
+		sign := "+"
+		if change.Cents < 0 {
+			sign = "-"
+		}
+		moneyData.WriteString(fmt.Sprintf("\nChange: %s %s", sign, displayAmount(change.Abs(), cfg)))
+	}
+
+	// Balance: 100.00 $ / 50.00 $ (live when advanced_balance is on)
+	balance := tx.Balance
+	var pending types.Money
+	if cfg.AdvancedBalance && liveBalance.Usd != "" {
+		balance = liveBalance.Available()
+		pending = liveBalance.TradeProtected()
+	}
+	if !balance.IsZero() || !pending.IsZero() {
+		moneyData.WriteString(fmt.Sprintf("\nBalance: %s", displayAmount(balance, cfg)))
+		if pending.Cents > 0 {
+			moneyData.WriteString(fmt.Sprintf(" / %s", displayAmount(pending, cfg)))
+		}
+	}
+
+	if moneyData.Len() > 0 {
+		message.WriteString("\n" + moneyData.String())
+	}
+	return message.String()
+}
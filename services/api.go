@@ -0,0 +1,227 @@
+package services
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+)
+
+// APIHandler serves the read-only REST API for other bots, from the same ledger and cost map the trackers write:
+//
+//	/accounts          per-account summary (as on the dashboard) with its polling health
+//	/transactions      ledger entries, newest first (?account=&type=&since=&q=&float=&seed=&limit=100)
+//	/costs/{itemId}    buy price and date of one item
+//	/pnl               realized profit per period (?account=&period=day|week|month|year&since=)
+//	/inventory         held items with their buy price, fetched live from DMarket (?account=)
+//	/balances          scheduled balance snapshots per account (?account=&since=)
+//
+// Every request needs "Authorization: Bearer <token>" with a token from tokens (consumer name -> token).
+func APIHandler(tokens map[string]string, accounts func() []types.AccountConfig, shared *Shared) http.Handler {
+	d := &dashboard{accounts: accounts, shared: shared}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /accounts", d.serveAPIAccounts)
+	mux.HandleFunc("GET /transactions", d.serveTransactions)
+	mux.HandleFunc("GET /costs/{itemId}", d.serveCost)
+	mux.HandleFunc("GET /pnl", d.servePeriodPnL)
+	mux.HandleFunc("GET /inventory", d.serveInventory)
+	mux.HandleFunc("GET /balances", d.serveBalances)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		consumer, ok := authenticate(tokens, r)
+		if !ok {
+			slog.Warn("API request rejected", "remote", r.RemoteAddr, "path", r.URL.Path)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="dmarket-tracker"`)
+			http.Error(w, "missing or invalid API token", http.StatusUnauthorized)
+			return
+		}
+		slog.Debug("API request", "consumer", consumer, "path", r.URL.Path, "query", r.URL.RawQuery)
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// authenticate returns the consumer owning the request's bearer token
+func authenticate(tokens map[string]string, r *http.Request) (string, bool) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || token == "" {
+		return "", false
+	}
+	for consumer, expected := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1 {
+			return consumer, true
+		}
+	}
+	return "", false
+}
+
+func (d *dashboard) serveAPIAccounts(w http.ResponseWriter, r *http.Request) {
+	health := make(map[string]types.AccountHealth)
+	for _, state := range d.shared.Health.Snapshot() {
+		health[state.Label] = state
+	}
+
+	type account struct {
+		types.DashboardAccount
+		Health *types.AccountHealth `json:"health,omitempty"` // Missing while the account is starting
+	}
+	result := []account{}
+	for _, cfg := range d.accounts() {
+		row := account{DashboardAccount: SummarizeAccount(cfg, d.shared.Ledger.Entries(cfg.Label))}
+		if state, ok := health[cfg.Label]; ok {
+			row.Health = &state
+		}
+		result = append(result, row)
+	}
+	writeJSON(w, result)
+}
+
+func (d *dashboard) serveCost(w http.ResponseWriter, r *http.Request) {
+	itemID := r.PathValue("itemId")
+
+	d.shared.CostMu.RLock()
+	cost, found := d.shared.Costs[itemID]
+	d.shared.CostMu.RUnlock()
+
+	if !found {
+		http.Error(w, "no buy price known for this item", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, struct {
+		ItemID string `json:"itemId"`
+		types.CostEntry
+	}{itemID, cost})
+}
+
+func (d *dashboard) servePeriodPnL(w http.ResponseWriter, r *http.Request) {
+	entries, ok := d.entries(w, r)
+	if !ok {
+		return
+	}
+	filter, err := parseEntryFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "month"
+	}
+
+	var selected []types.LedgerEntry
+	for _, entry := range entries {
+		if filter.match(entry) {
+			selected = append(selected, entry)
+		}
+	}
+	result, err := RealizedPnL(selected, period, time.Local, d.rates)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, result)
+}
+
+// serveInventory fetches the DMarket inventory of the selected accounts (through the key's rate limit)
+// and joins it with the cost map. Market prices are not looked up, see the "report" command for that.
+func (d *dashboard) serveInventory(w http.ResponseWriter, r *http.Request) {
+	label := r.URL.Query().Get("account")
+
+	reports := []types.InventoryReport{}
+	found := false
+	for _, cfg := range d.accounts() {
+		if label != "" && cfg.Label != label {
+			continue
+		}
+		found = true
+
+		ctx := WithAccount(r.Context(), cfg.Label)
+		inventory, err := FetchDMarketInventory(ctx, cfg.DMarketKey)
+		if err != nil {
+			Logger(ctx).Warn("API inventory fetch failed", "error", err)
+			http.Error(w, fmt.Sprintf("%s: fetching inventory failed", cfg.Label), http.StatusBadGateway)
+			return
+		}
+		reports = append(reports, ValueInventory(cfg.Label, inventory, nil, d.shared.Costs, d.shared.CostMu, RateProviderFor(cfg)))
+	}
+	if !found {
+		http.Error(w, "unknown account", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, reports)
+}
+
+func (d *dashboard) serveBalances(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var since int64
+	if raw := query.Get("since"); raw != "" {
+		t, err := parseSince(raw)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		since = t.Unix()
+	}
+
+	label := query.Get("account")
+	result := make(map[string][]types.BalanceSnapshot)
+	for _, cfg := range d.accounts() {
+		if label == "" || cfg.Label == label {
+			result[cfg.Label] = d.shared.Balances.History(cfg.Label, since)
+		}
+	}
+	if label != "" && len(result) == 0 {
+		http.Error(w, "unknown account", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, result)
+}
+
+// RealizedPnL sums the profit of sells with a known buy price per day, week (ISO), month or year
+// in loc, oldest period first. Periods without sells are left out. Profits are summed in USD with the rates of each entry's account.
+func RealizedPnL(entries []types.LedgerEntry, period string, loc *time.Location, rates func(account string) RateProvider) ([]types.PeriodPnL, error) {
+	var key func(t time.Time) string
+	switch period {
+	case "day":
+		key = func(t time.Time) string { return t.Format("2006-01-02") }
+	case "week":
+		key = func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}
+	case "month":
+		key = func(t time.Time) string { return t.Format("2006-01") }
+	case "year":
+		key = func(t time.Time) string { return t.Format("2006") }
+	default:
+		return nil, fmt.Errorf("invalid period %q (day, week, month, year)", period)
+	}
+
+	totals := make(map[string]*types.PeriodPnL)
+	for _, entry := range entries {
+		if entry.Action != "Sell" || entry.Status == "reverted" || entry.BuyPrice.Cents <= 0 {
+			continue
+		}
+		k := key(time.Unix(entry.Time, 0).In(loc))
+		total, ok := totals[k]
+		if !ok {
+			total = &types.PeriodPnL{Period: k, Profit: types.USD(0)}
+			totals[k] = total
+		}
+		total.Profit = addUSD(total.Profit, entry.Profit, rates(entry.Account))
+		total.Sells++
+	}
+
+	result := []types.PeriodPnL{}
+	for _, total := range totals {
+		result = append(result, *total)
+	}
+	// All keys of one period sort chronologically as text
+	sort.Slice(result, func(i, j int) bool { return result[i].Period < result[j].Period })
+	return result, nil
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+)
+
+// Backfill rebuilds ledger entries (with profit) for transactions created since the given time.
+// The cost basis is reconstructed chronologically from the whole history, so a buy only prices sells after it.
+func Backfill(ctx context.Context, cfg types.AccountConfig, since int64, ledger *Ledger) (types.BackfillSummary, error) {
+	summary := types.BackfillSummary{Label: cfg.Label, Since: since}
+
+	// 1. Whole history (buys before "since" still price the sells after it)
+	ctx = WithAccount(ctx, cfg.Label)
+	Logger(ctx).Info("Fetching full history")
+	history, err := FetchFullHistory(ctx, cfg.DMarketKey, 0)
+	if err != nil {
+		return summary, err
+	}
+
+	// 2. CSFloat buys, matched to DMarket sells by float/seed
+	var csBuys []types.CSFloatTrade
+	if cfg.CSFloatKey != "" {
+		if csBuys, err = FetchCSFloatTrades(ctx, cfg.CSFloatKey, "buyer", "verified"); err != nil {
+			return summary, err
+		}
+		sort.SliceStable(csBuys, func(i, j int) bool { return csfloatTradeTime(csBuys[i]) < csfloatTradeTime(csBuys[j]) })
+	}
+
+	// 3. Replay
+	costs := make(types.CostMap)
+	var mu sync.RWMutex
+	csFingerprints := make(map[string]types.CostEntry)
+	nextCS := 0
+
+	for _, tx := range history {
+		// CSFloat buys that happened before this transaction
+		for nextCS < len(csBuys) && csfloatTradeTime(csBuys[nextCS]) <= tx.CreatedAt {
+			trade := csBuys[nextCS]
+			nextCS++
+			if trade.Contract.Item.FloatValue > 0 {
+				csFingerprints[fingerprint(trade.Contract.Item.FloatValue, trade.Contract.Item.PaintSeed)] = types.CostEntry{
+					Price:      types.USD(int64(trade.Contract.Price)),
+					AcquiredAt: csfloatTradeTime(trade),
+				}
+			}
+		}
+
+		// Sells of CSFloat items: price them by fingerprint
+		if tx.Action == "Sell" && tx.Details.ItemID != "" && tx.Details.Extra.FloatValue > 0 {
+			if _, known := costs[tx.Details.ItemID]; !known {
+				if entry, found := csFingerprints[fingerprint(tx.Details.Extra.FloatValue, tx.Details.Extra.PaintSeed)]; found {
+					costs[tx.Details.ItemID] = entry
+				}
+			}
+		}
+
+		if tx.CreatedAt >= since {
+			entry := NewLedgerEntry(cfg.Label, tx, costs, &mu, RateProviderFor(cfg))
+
+			if entry.Action == "Sell" && entry.Status != "reverted" {
+				summary.Sells++
+				if !entry.BuyPrice.IsZero() {
+					summary.Matched++
+					summary.Profit = addUSD(summary.Profit, entry.Profit, RateProviderFor(cfg))
+				}
+			}
+
+			// Only write what changed
+			if stored, found := ledger.Lookup(cfg.Label, tx.ID); !found || !sameLedgerEntry(stored, entry) {
+				if !cfg.DryRun {
+					if err := ledger.Record(entry); err != nil {
+						return summary, err
+					}
+				}
+				summary.Stored++
+			}
+		}
+
+		// Learn buys after pricing (same order as the live tracker)
+		if (tx.Type == "target_closed" || tx.Type == "purchase") && tx.Details.ItemID != "" && tx.Status != "reverted" && len(tx.Changes) > 0 {
+			costs[tx.Details.ItemID] = types.CostEntry{Price: tx.Changes[0].Money, AcquiredAt: tx.CreatedAt}
+		}
+	}
+
+	return summary, nil
+}
+
+// FormatBackfillSummary renders a one-message summary of a backfill run
+func FormatBackfillSummary(summary types.BackfillSummary) string {
+	return fmt.Sprintf("Backfill %s since %s\nSells: %d (%d with buy price)\nProfit: %s\nLedger entries written: %d",
+		summary.Label,
+		time.Unix(summary.Since, 0).UTC().Format("2006-01-02"),
+		summary.Sells,
+		summary.Matched,
+		signed(summary.Profit),
+		summary.Stored,
+	)
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+)
+
+// Highest marketplace fee on a sale, the balance can grow by anything between amount*(1-maxSellFee) and amount
+const maxSellFee = 0.10
+
+// BalanceStore is an append-only JSON-lines log of balance snapshots. It also collects the balance change
+// the tracker expects from the transactions it saw, so the poller can spot changes nobody explains.
+type BalanceStore struct {
+	mu        sync.RWMutex
+	file      *os.File
+	snapshots map[string][]types.BalanceSnapshot // By account, oldest first
+	expected  map[string]expectedChange
+}
+
+// expectedChange is the range the total balance should have moved by
+type expectedChange struct {
+	low, high types.Money
+}
+
+// OpenBalances loads the existing snapshots (if any) and opens the file for appending
+func OpenBalances(path string) (*BalanceStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &BalanceStore{
+		file:      file,
+		snapshots: make(map[string][]types.BalanceSnapshot),
+		expected:  make(map[string]expectedChange),
+	}
+
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var snapshot types.BalanceSnapshot
+		if err := json.Unmarshal(scanner.Bytes(), &snapshot); err != nil {
+			slog.Warn("Balance line skipped", "line", line, "error", err)
+			continue
+		}
+		store.snapshots[snapshot.Account] = append(store.snapshots[snapshot.Account], snapshot)
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// Record stores the snapshot in memory and appends it to the file
+func (b *BalanceStore) Record(snapshot types.BalanceSnapshot) error {
+	line, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.snapshots[snapshot.Account] = append(b.snapshots[snapshot.Account], snapshot)
+	if b.file == nil {
+		return fmt.Errorf("balance file closed")
+	}
+	_, err = b.file.Write(append(line, '\n'))
+	return err
+}
+
+// History returns an account's snapshots taken at or after since, oldest first
+func (b *BalanceStore) History(account string, since int64) []types.BalanceSnapshot {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	result := []types.BalanceSnapshot{}
+	for _, snapshot := range b.snapshots[account] {
+		if snapshot.Time >= since {
+			result = append(result, snapshot)
+		}
+	}
+	return result
+}
+
+// Close closes the file, closing twice is a no-op
+func (b *BalanceStore) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.file == nil {
+		return nil
+	}
+	err := b.file.Close()
+	b.file = nil
+	return err
+}
+
+// Expect adds the balance change of a transaction the tracker just saw, in USD like the snapshots.
+// isNew is false for a status update of a transaction seen before.
+func (b *BalanceStore) Expect(account string, tx types.Transaction, isNew bool, rates RateProvider) {
+	low, high := transactionBalanceChange(tx, isNew)
+	if low.IsZero() && high.IsZero() {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	change := b.expected[account]
+	change.low = addUSD(change.low, low, rates)
+	change.high = addUSD(change.high, high, rates)
+	b.expected[account] = change
+}
+
+// takeExpected returns and resets the change collected since the last call
+func (b *BalanceStore) takeExpected(account string) expectedChange {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	change := b.expected[account]
+	delete(b.expected, account)
+	return change
+}
+
+// transactionBalanceChange is the range a transaction moves the total balance (available + trade protected) by.
+// New sells add their amount minus the fee, buys and withdrawals take theirs, deposits and refunds add theirs,
+// a revert undoes it. Other status updates and item transfers move nothing.
+func transactionBalanceChange(tx types.Transaction, isNew bool) (low, high types.Money) {
+	if len(tx.Changes) == 0 {
+		return
+	}
+	amount := tx.Changes[0].Money.Abs()
+
+	switch {
+	case isNew && tx.Status == "reverted", !isNew && tx.Status != "reverted":
+		return
+	case tx.Action == "Sell":
+		low, high = amount.Sub(amount.MulRate(maxSellFee)), amount
+	case tx.Type == "purchase" || tx.Type == "target_closed":
+		low, high = amount.Neg(), amount.Neg()
+	case ActivityFlow(tx) == FlowCapital:
+		low, high = capitalChange(tx), capitalChange(tx)
+	default:
+		return
+	}
+
+	if !isNew {
+		// Reverted: the other way round
+		low, high = high.Neg(), low.Neg()
+	}
+	return low, high
+}
+
+// StartBalancePoller snapshots the account's balance every BalanceInterval until ctx is cancelled.
+// It alerts when the available balance drops below MinBalance and, with BalanceChangeAlert,
+// when the total changes by more than BalanceTolerance beyond what the tracker's transactions explain.
+func StartBalancePoller(ctx context.Context, handle *AccountHandle, shared *Shared, wg *sync.WaitGroup) {
+	defer wg.Done()
+	ctx = WithAccount(ctx, handle.Config().Label)
+	log := Logger(ctx)
+
+	var (
+		baseline *types.BalanceSnapshot // Last balance that matched the transactions
+		expected expectedChange         // Collected since the baseline
+		suspect  bool                   // Mismatch seen once, confirmed on the next poll
+		below    bool                   // Under MinBalance, waiting to recover
+	)
+
+	for {
+		cfg := handle.Config()
+
+		balance, err := FetchUserBalance(ctx, cfg.DMarketKey)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Warn("Fetching balance failed", "error", err)
+			}
+			if !sleepContext(ctx, time.Duration(cfg.BalanceInterval)) {
+				return
+			}
+			continue
+		}
+
+		snapshot := types.BalanceSnapshot{
+			Account:        cfg.Label,
+			Time:           time.Now().Unix(),
+			Available:      balance.Available(),
+			TradeProtected: balance.TradeProtected(),
+		}
+		if !cfg.DryRun {
+			if err := shared.Balances.Record(snapshot); err != nil {
+				log.Error("Saving balance failed", "error", err)
+			}
+		}
+		balanceAvailable.WithLabelValues(cfg.Label).Set(snapshot.Available.Float())
+		balanceTradeProtected.WithLabelValues(cfg.Label).Set(snapshot.TradeProtected.Float())
+
+		// 1. Threshold
+		if cfg.MinBalance.Cents > 0 {
+			isBelow := snapshot.Available.Cents < cfg.MinBalance.Cents
+			switch {
+			case isBelow && !below:
+				log.Warn("Balance below minimum", "available", snapshot.Available.String(), "min", cfg.MinBalance.String())
+				sendAlert(shared, cfg, fmt.Sprintf("⚠️ %s: available balance %s is below %s",
+					cfg.Label, formatAmount(snapshot.Available), formatAmount(cfg.MinBalance)))
+			case !isBelow && below:
+				log.Info("Balance back above minimum", "available", snapshot.Available.String())
+				sendAlert(shared, cfg, fmt.Sprintf("✅ %s: available balance back to %s", cfg.Label, formatAmount(snapshot.Available)))
+			}
+			below = isBelow
+		}
+
+		// 2. Change without matching transactions
+		change := shared.Balances.takeExpected(cfg.Label)
+		if baseline == nil {
+			// Transactions seen before the first snapshot are already in it
+			baseline = &snapshot
+		} else {
+			// Both USD: snapshots are, Expect converted the transactions
+			expected.low = expected.low.Add(change.low)
+			expected.high = expected.high.Add(change.high)
+
+			delta := snapshot.Total().Sub(baseline.Total())
+			unexplained := unexplainedChange(delta, expected, cfg.BalanceTolerance)
+
+			switch {
+			case unexplained.IsZero():
+				suspect = false
+			case !suspect:
+				// The tracker may not have seen the transaction yet, check again next time
+				suspect = true
+			default:
+				suspect = false
+				log.Warn("Balance changed without matching transactions", "change", delta.String(), "unexplained", unexplained.String())
+				if cfg.BalanceChangeAlert {
+					sendAlert(shared, cfg, fmt.Sprintf("⚠️ %s: balance changed by %s, %s not explained by trades (deposit, withdrawal or fee?)\nAvailable: %s\nTrade protected: %s",
+						cfg.Label, signed(delta), signed(unexplained), formatAmount(snapshot.Available), formatAmount(snapshot.TradeProtected)))
+				}
+			}
+			if !suspect {
+				baseline = &snapshot
+				expected = expectedChange{}
+			}
+		}
+
+		if !sleepContext(ctx, time.Duration(cfg.BalanceInterval)) {
+			return
+		}
+	}
+}
+
+// unexplainedChange is how far delta lies outside the expected range widened by tolerance (zero if inside)
+func unexplainedChange(delta types.Money, expected expectedChange, tolerance types.Money) types.Money {
+	switch {
+	case delta.Cents < expected.low.Sub(tolerance).Cents:
+		return delta.Sub(expected.low)
+	case delta.Cents > expected.high.Add(tolerance).Cents:
+		return delta.Sub(expected.high)
+	}
+	return types.Money{}
+}
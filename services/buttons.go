@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Callback data of the "Mark cost" button, followed by the item ID (Telegram allows 64 bytes)
+const markCostPrefix = "cost:"
+
+// The cost prompt names its item like this, so a reply to it finds the item again
+var costPromptItem = regexp.MustCompile(`\(item ([^)\s]+)\)`)
+
+// transactionButtons is the inline keyboard under a trade or item transfer (nil when buttons are off).
+// Telegram only opens http(s) links from buttons, so the steam:// inspect link goes through the CSFloat checker.
+func transactionButtons(tx types.Transaction, cfg types.AccountConfig) *tgbotapi.InlineKeyboardMarkup {
+	if !cfg.Buttons || tx.Subject == "" {
+		return nil
+	}
+	title := url.QueryEscape(tx.Subject)
+
+	links := []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonURL("DMarket", "https://dmarket.com/ingame-items/item-list/csgo-skins?title="+title),
+		tgbotapi.NewInlineKeyboardButtonURL("CSFloat", "https://csfloat.com/search?market_hash_name="+title),
+	}
+	if inspect := tx.Details.Extra.InspectInGame; inspect != "" {
+		links = append(links, tgbotapi.NewInlineKeyboardButtonURL("Inspect", "https://csfloat.com/checker?inspect="+url.QueryEscape(inspect)))
+	}
+	rows := [][]tgbotapi.InlineKeyboardButton{links}
+
+	// Buys can get their price corrected by hand (fees, bundles, items bought elsewhere)
+	isBuy := tx.Type == "purchase" || tx.Type == "target_closed"
+	if isBuy && tx.Status != "reverted" && tx.Details.ItemID != "" && len(markCostPrefix+tx.Details.ItemID) <= 64 {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Mark cost", markCostPrefix+tx.Details.ItemID),
+		))
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &keyboard
+}
+
+// formatButtons lists the button labels for dry-run output
+func formatButtons(keyboard *tgbotapi.InlineKeyboardMarkup) string {
+	var labels []string
+	for _, row := range keyboard.InlineKeyboard {
+		for _, button := range row {
+			labels = append(labels, "["+button.Text+"]")
+		}
+	}
+	return strings.Join(labels, " ")
+}
+
+// handleCallback answers a button press in one of the accounts' chats
+func handleCallback(bot *tgbotapi.BotAPI, accounts func() []types.AccountConfig, shared *Shared, query *tgbotapi.CallbackQuery) {
+	log := slog.With("bot", bot.Self.UserName)
+	answer := func(text string) {
+		if _, err := bot.Request(tgbotapi.NewCallback(query.ID, text)); err != nil {
+			log.Warn("Answering button failed", "error", err)
+		}
+	}
+
+	var matched []types.AccountConfig
+	if query.Message != nil {
+		matched = accountsForChat(accounts(), bot.Token, query.Message.Chat)
+	}
+	if len(matched) == 0 {
+		answer("This button doesn't work here anymore")
+		return
+	}
+
+	itemID, ok := strings.CutPrefix(query.Data, markCostPrefix)
+	if !ok || itemID == "" {
+		answer("Unknown button")
+		return
+	}
+	answer("Reply with the buy price")
+
+	shared.CostMu.RLock()
+	cost, known := shared.Costs[itemID]
+	shared.CostMu.RUnlock()
+	current := "unknown"
+	if known {
+		current = formatAmount(cost.Price)
+	}
+
+	prompt := tgbotapi.NewMessage(query.Message.Chat.ID, fmt.Sprintf(
+		"Buy price for %s (item %s)?\nCurrent: %s\nReply to this message with the amount in USD (e.g. 12.34), or send /cost %s 12.34",
+		itemTitle(shared, matched, itemID), itemID, current, itemID))
+	prompt.ReplyToMessageID = query.Message.MessageID
+	prompt.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true, Selective: true}
+	if _, err := bot.Send(prompt); err != nil {
+		log.Error("Telegram send failed", "chat", query.Message.Chat.ID, "error", err)
+	}
+}
+
+// handleCostReply sets the price from a reply to the cost prompt, other messages are ignored.
+// The price is stored off the listener (checking the inventory may take a while), wg waits for it.
+func handleCostReply(ctx context.Context, bot *tgbotapi.BotAPI, accounts []types.AccountConfig, shared *Shared, msg *tgbotapi.Message, wg *sync.WaitGroup) {
+	prompt := msg.ReplyToMessage
+	if prompt == nil || prompt.From == nil || prompt.From.ID != bot.Self.ID {
+		return
+	}
+	if match := costPromptItem.FindStringSubmatch(prompt.Text); match != nil {
+		answerCost(ctx, bot, msg.Chat.ID, accounts, shared, match[1], msg.Text, wg)
+	}
+}
+
+// answerCost marks the price in the background and replies with the outcome
+func answerCost(ctx context.Context, bot *tgbotapi.BotAPI, chatID int64, accounts []types.AccountConfig, shared *Shared, itemID, rawPrice string, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		answer := markCost(ctx, shared, accounts, itemID, rawPrice)
+		if ctx.Err() == nil {
+			sendLongMessage(bot, chatID, answer)
+		}
+	}()
+}
+
+// markCost parses the price ("12.34", "12,34 $") and stores it as the item's buy price, returning the answer.
+// The item must be in the ledger or the DMarket inventory of one of the accounts (those of the chat).
+func markCost(ctx context.Context, shared *Shared, accounts []types.AccountConfig, itemID, rawPrice string) string {
+	amount := strings.TrimSpace(strings.ReplaceAll(rawPrice, "$", ""))
+	// "12,34" is a decimal comma, anything else with a comma ("1,234.56", "1,2,3") is ambiguous
+	if !strings.Contains(amount, ".") && strings.Count(amount, ",") == 1 {
+		amount = strings.Replace(amount, ",", ".", 1)
+	}
+	price, err := types.ParseMoney(amount, "USD")
+	if err != nil || price.Cents <= 0 {
+		return fmt.Sprintf("%q is not a price, send e.g. 12.34", strings.TrimSpace(rawPrice))
+	}
+
+	owned, err := ownsItem(ctx, shared, accounts, itemID)
+	if err != nil {
+		return fmt.Sprintf("Couldn't check item %s, try again later: %s", itemID, Redact(err.Error()))
+	}
+	if !owned {
+		return fmt.Sprintf("Item %s is not in the ledger or inventory of %s", itemID, accountLabels(accounts))
+	}
+
+	if err := shared.ManualCosts.Mark(itemID, price, shared.Costs, shared.CostMu); err != nil {
+		slog.Error("Saving manual cost failed", "item", itemID, "error", err)
+		return fmt.Sprintf("Buy price set to %s for this run, saving it failed: %s", formatAmount(price), Redact(err.Error()))
+	}
+	slog.Info("Buy price marked", "item", itemID, "price", price.String())
+	return fmt.Sprintf("Buy price of item %s set to %s, its sale will use it for the profit", itemID, formatAmount(price))
+}
+
+// ownsItem tells if the item is in the ledger of one of the accounts, or else in one of their DMarket inventories
+func ownsItem(ctx context.Context, shared *Shared, accounts []types.AccountConfig, itemID string) (bool, error) {
+	if _, found := ledgerEntryFor(shared, accounts, itemID); found {
+		return true, nil
+	}
+	for _, cfg := range accounts {
+		inventory, err := FetchDMarketInventory(WithAccount(ctx, cfg.Label), cfg.DMarketKey)
+		if err != nil {
+			return false, err
+		}
+		for _, item := range inventory {
+			if item.ItemID == itemID {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// ledgerEntryFor finds the latest ledger entry of the item in the accounts' ledgers
+func ledgerEntryFor(shared *Shared, accounts []types.AccountConfig, itemID string) (types.LedgerEntry, bool) {
+	for _, cfg := range accounts {
+		entries := shared.Ledger.Entries(cfg.Label)
+		for i := len(entries) - 1; i >= 0; i-- {
+			if entries[i].ItemID == itemID {
+				return entries[i], true
+			}
+		}
+	}
+	return types.LedgerEntry{}, false
+}
+
+// itemTitle finds the item's name in the accounts' ledgers (the ID if it isn't there)
+func itemTitle(shared *Shared, accounts []types.AccountConfig, itemID string) string {
+	if entry, found := ledgerEntryFor(shared, accounts, itemID); found && entry.Title != "" {
+		return entry.Title
+	}
+	return itemID
+}
+
+// accountLabels joins the labels for replies: "Main, Alt"
+func accountLabels(accounts []types.AccountConfig) string {
+	labels := make([]string, 0, len(accounts))
+	for _, cfg := range accounts {
+		labels = append(labels, cfg.Label)
+	}
+	return strings.Join(labels, ", ")
+}
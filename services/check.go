@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// CheckAccount makes one real call per credential: signed DMarket balance, CSFloat profile,
+// Telegram getMe and getChat. Auth errors are never retried, so a bad key fails fast.
+func CheckAccount(ctx context.Context, cfg types.AccountConfig) types.CheckResult {
+	ctx = WithAccount(ctx, cfg.Label)
+	result := types.CheckResult{Label: cfg.Label, OK: true}
+
+	fail := func(err error) string {
+		result.OK = false
+		return "FAIL: " + Redact(err.Error()) // Telegram errors contain the bot URL with the token
+	}
+
+	// 1. DMarket (signed)
+	result.DMarket = "ok"
+	if _, err := FetchUserBalance(ctx, cfg.DMarketKey); err != nil {
+		result.DMarket = fail(err)
+	}
+
+	// 2. CSFloat
+	result.CSFloat = "skipped"
+	if cfg.CSFloatKey != "" {
+		result.CSFloat = "ok"
+		if err := checkCSFloatKey(ctx, cfg.CSFloatKey); err != nil {
+			result.CSFloat = fail(err)
+		}
+	}
+
+	// 3. Telegram
+	result.Telegram = "skipped"
+	result.Chat = "skipped"
+	if cfg.TelegramToken != "" {
+		bot, err := tgbotapi.NewBotAPI(cfg.TelegramToken) // Calls getMe
+		if err != nil {
+			result.Telegram = fail(err)
+			return result
+		}
+		result.Telegram = "ok (@" + bot.Self.UserName + ")"
+
+		chat, err := bot.GetChat(tgbotapi.ChatInfoConfig{ChatConfig: chatConfig(cfg.TelegramChatID)})
+		if err != nil {
+			result.Chat = fail(err)
+		} else {
+			result.Chat = "ok (" + chat.Type + ")"
+		}
+	}
+
+	return result
+}
+
+// chatConfig accepts numeric chat IDs and @channelname
+func chatConfig(chatID string) tgbotapi.ChatConfig {
+	if id, err := strconv.ParseInt(chatID, 10, 64); err == nil {
+		return tgbotapi.ChatConfig{ChatID: id}
+	}
+	return tgbotapi.ChatConfig{SuperGroupUsername: chatID}
+}
+
+func checkCSFloatKey(ctx context.Context, apiKey string) error {
+	client := &http.Client{Timeout: 15 * time.Second}
+	req, _ := http.NewRequestWithContext(ctx, "GET", "https://csfloat.com/api/v1/me", nil)
+	req.Header.Set("Authorization", apiKey)
+
+	resp, err := doRequest(ctx, client, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// FormatCheckTable renders results as an aligned pass/fail table
+func FormatCheckTable(results []types.CheckResult) string {
+	header := []string{"ACCOUNT", "DMARKET", "CSFLOAT", "TELEGRAM", "CHAT"}
+	rows := [][]string{header}
+	for _, r := range results {
+		rows = append(rows, []string{r.Label, r.DMarket, r.CSFloat, r.Telegram, r.Chat})
+	}
+
+	widths := make([]int, len(header))
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+
+	var sb strings.Builder
+	for _, row := range rows {
+		for i, cell := range row {
+			sb.WriteString(cell + strings.Repeat(" ", widths[i]-len(cell)+2))
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
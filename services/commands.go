@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Telegram refuses messages longer than this
+const telegramMessageLimit = 4096
+
+// StartCommandListener answers bot commands sent in the chats of the accounts using this bot.
+// Only one listener may run per bot token (Telegram allows a single getUpdates consumer).
+// It stops when ctx is cancelled.
+func StartCommandListener(ctx context.Context, bot *tgbotapi.BotAPI, accounts func() []types.AccountConfig, shared *Shared, wg *sync.WaitGroup) {
+	defer wg.Done()
+	log := slog.With("bot", bot.Self.UserName)
+	log.Info("Command listener started")
+	defer log.Info("Command listener stopped")
+
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = 60
+
+	updates := bot.GetUpdatesChan(u)
+
+	for {
+		var update tgbotapi.Update
+		select {
+		case <-ctx.Done():
+			// Don't wait for the pending long poll, it ends on its own
+			bot.StopReceivingUpdates()
+			return
+		case next, ok := <-updates:
+			if !ok {
+				return
+			}
+			update = next
+		}
+
+		// Buttons under the posted messages
+		if update.CallbackQuery != nil {
+			handleCallback(bot, accounts, shared, update.CallbackQuery)
+			continue
+		}
+
+		// Commands can arrive from groups/private chats or from channels
+		msg := update.Message
+		if msg == nil {
+			msg = update.ChannelPost
+		}
+		if msg == nil {
+			continue
+		}
+		if !msg.IsCommand() {
+			// Answer to a "Mark cost" prompt, only from the accounts' chats
+			if matched := accountsForChat(accounts(), bot.Token, msg.Chat); len(matched) > 0 {
+				handleCostReply(ctx, bot, matched, shared, msg, wg)
+			}
+			continue
+		}
+
+		// Accounts are looked up per message, so reloaded configs apply immediately
+		matched := accountsForChat(accounts(), bot.Token, msg.Chat)
+		if len(matched) == 0 {
+			continue
+		}
+
+		switch msg.Command() {
+		case "inventory":
+			// One market lookup per title takes a while, keep answering buttons and other commands meanwhile
+			wg.Add(1)
+			go sendInventoryReports(ctx, bot, msg.Chat.ID, matched, shared, wg)
+		case "holding":
+			for _, cfg := range matched {
+				sendLongMessage(bot, msg.Chat.ID, FormatHoldingReport(cfg, shared.Ledger.Entries(cfg.Label)))
+			}
+		case "cost":
+			// /cost <itemId> <price>
+			args := strings.Fields(msg.CommandArguments())
+			if len(args) != 2 {
+				sendLongMessage(bot, msg.Chat.ID, "Usage: /cost <itemId> <price>, e.g. /cost 1a2b3c... 12.34")
+				continue
+			}
+			answerCost(ctx, bot, msg.Chat.ID, matched, shared, args[0], args[1], wg)
+		case "pending":
+			for _, cfg := range matched {
+				pending := PendingReleases(shared.Ledger.Entries(cfg.Label))
+				sendLongMessage(bot, msg.Chat.ID, FormatPendingReleases(cfg, pending, time.Now()))
+			}
+		}
+	}
+}
+
+// sendInventoryReports builds and sends the inventory report of every account, one after another
+func sendInventoryReports(ctx context.Context, bot *tgbotapi.BotAPI, chatID int64, accounts []types.AccountConfig, shared *Shared, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for _, cfg := range accounts {
+		report, err := BuildInventoryReport(ctx, cfg, shared.Costs, shared.CostMu)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			sendLongMessage(bot, chatID, fmt.Sprintf("Inventory error for %s: %s", cfg.Label, Redact(err.Error())))
+			continue
+		}
+		sendLongMessage(bot, chatID, FormatInventoryReport(report))
+	}
+}
+
+// accountsForChat returns the accounts that post to this chat with this bot
+func accountsForChat(configs []types.AccountConfig, token string, chat *tgbotapi.Chat) []types.AccountConfig {
+	var matched []types.AccountConfig
+	chatID := strconv.FormatInt(chat.ID, 10)
+
+	for _, cfg := range configs {
+		if cfg.TelegramToken != token {
+			continue
+		}
+		// Chat ID may be numeric or "@channelname"
+		if cfg.TelegramChatID == chatID || (chat.UserName != "" && cfg.TelegramChatID == "@"+chat.UserName) {
+			matched = append(matched, cfg)
+		}
+	}
+	return matched
+}
+
+// sendLongMessage splits text into Telegram-sized chunks (on line breaks) and sends them in order
+func sendLongMessage(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	for len(text) > 0 {
+		chunk := text
+		if len(chunk) > telegramMessageLimit {
+			chunk = text[:telegramMessageLimit]
+			// Prefer cutting at the last newline
+			for i := len(chunk) - 1; i > 0; i-- {
+				if chunk[i] == '\n' {
+					chunk = chunk[:i+1]
+					break
+				}
+			}
+		}
+		text = text[len(chunk):]
+
+		if _, err := bot.Send(tgbotapi.NewMessage(chatID, chunk)); err != nil {
+			slog.Error("Telegram send failed", "chat", chatID, "error", err)
+		}
+	}
+}
@@ -1,22 +1,379 @@
 package services
 
 import (
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+	"gopkg.in/yaml.v3"
 )
 
-func LoadConfig(path string) ([]types.AccountConfig, error) {
-	file, err := os.Open(path)
+var (
+	telegramTokenPattern  = regexp.MustCompile(`^\d+:[A-Za-z0-9_-]{30,}$`)
+	telegramChatIDPattern = regexp.MustCompile(`^(-?\d+|@[A-Za-z][A-Za-z0-9_]{3,})$`)
+)
+
+// Config files are looked up in this order when no path is given
+var defaultConfigPaths = []string{"config/config.yaml", "config/config.yml", "config/config.toml", "config/config.json"}
+
+// Keys that may be given as "<key>_file": the secret is read from that file instead
+var secretFileKeys = []string{"dmarket_key", "csfloat_key", "telegram_token"}
+
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// FindConfig returns the first existing default config file
+func FindConfig() (string, error) {
+	for _, path := range defaultConfigPaths {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no config found (tried %s)", strings.Join(defaultConfigPaths, ", "))
+}
+
+// LoadConfig reads a YAML, TOML or JSON config (by extension).
+// The file is either the legacy bare list of accounts or a structured config:
+// global settings, "defaults" applied to every account, named "notifiers" and "accounts".
+// String values support ${ENV} interpolation, and secrets can be given as <key>_file.
+func LoadConfig(path string) (types.Config, error) {
+	var config types.Config
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+
+	// 1. Decode into generic values (same shape for all formats)
+	var raw interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		var table map[string]interface{}
+		err = toml.Unmarshal(data, &table)
+		raw = table
+	default:
+		err = json.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		return config, fmt.Errorf("%s: %v", path, err)
+	}
+
+	// 2. ${ENV} everywhere
+	raw, err = interpolateEnv(raw)
+	if err != nil {
+		return config, err
+	}
+
+	// 3. Legacy format: a bare list of accounts
+	root, ok := raw.(map[string]interface{})
+	if !ok {
+		root = map[string]interface{}{"accounts": raw}
+	}
+
+	// 4. Merge defaults into every account (account values win) and resolve *_file secrets
+	defaults, _ := root["defaults"].(map[string]interface{})
+	accounts, _ := root["accounts"].([]interface{})
+	merged := make([]interface{}, 0, len(accounts))
+	for i, item := range accounts {
+		account, ok := item.(map[string]interface{})
+		if !ok {
+			return config, fmt.Errorf("account #%d is not an object", i+1)
+		}
+		combined := make(map[string]interface{})
+		for k, v := range defaults {
+			combined[k] = v
+		}
+		for k, v := range account {
+			combined[k] = v
+		}
+		if err := resolveSecretFiles(combined); err != nil {
+			return config, fmt.Errorf("account #%d: %v", i+1, err)
+		}
+		merged = append(merged, combined)
+	}
+	root["accounts"] = merged
+
+	if notifiers, ok := root["notifiers"].(map[string]interface{}); ok {
+		for name, n := range notifiers {
+			if notifier, ok := n.(map[string]interface{}); ok {
+				if err := resolveSecretFiles(notifier); err != nil {
+					return config, fmt.Errorf("notifier %s: %v", name, err)
+				}
+			}
+		}
+	}
+
+	// 5. Typed config (JSON is the common denominator)
+	normalized, err := json.Marshal(root)
 	if err != nil {
-		return nil, err
+		return config, err
 	}
-	defer file.Close()
+	if err := json.Unmarshal(normalized, &config); err != nil {
+		return config, fmt.Errorf("%s: %v", path, err)
+	}
+
+	if err := applyGlobals(&config); err != nil {
+		return config, err
+	}
+	if err := ValidateConfig(config.Accounts); err != nil {
+		return config, err
+	}
+	return config, nil
+}
 
-	var configs []types.AccountConfig
-	if err := json.NewDecoder(file).Decode(&configs); err != nil {
-		return nil, err
+// applyGlobals fills global defaults and per-account values inherited from them
+func applyGlobals(config *types.Config) error {
+	if config.PollInterval <= 0 {
+		config.PollInterval = types.Duration(15 * time.Second)
+	}
+	if config.HistoryLimit <= 0 {
+		config.HistoryLimit = 50
+	}
+	if config.StoragePath == "" {
+		config.StoragePath = "data"
+	}
+	if config.LogLevel == "" {
+		config.LogLevel = "info"
+	}
+	if config.LogFormat == "" {
+		config.LogFormat = "text"
 	}
-	return configs, nil
-}
\ No newline at end of file
+	if config.StaleAfter <= 0 {
+		config.StaleAfter = types.Duration(10 * time.Minute)
+	}
+	if config.BalanceInterval <= 0 {
+		config.BalanceInterval = types.Duration(5 * time.Minute)
+	}
+	if config.ReleaseDigest == "" {
+		config.ReleaseDigest = "08:30"
+	}
+
+	tokens := make(map[string]string)
+	for consumer, token := range config.APITokens {
+		if len(token) < 16 {
+			return fmt.Errorf("api_tokens.%s: token must be at least 16 characters", consumer)
+		}
+		if other, ok := tokens[token]; ok {
+			return fmt.Errorf("api_tokens.%s: same token as %s", consumer, other)
+		}
+		tokens[token] = consumer
+	}
+
+	var owner types.NotifierConfig
+	if config.OwnerNotifier != "" {
+		var ok bool
+		if owner, ok = config.Notifiers[config.OwnerNotifier]; !ok {
+			return fmt.Errorf("unknown owner_notifier %q", config.OwnerNotifier)
+		}
+	}
+
+	for i := range config.Accounts {
+		account := &config.Accounts[i]
+		if account.PollInterval <= 0 {
+			account.PollInterval = config.PollInterval
+		}
+		if account.HistoryLimit <= 0 {
+			account.HistoryLimit = config.HistoryLimit
+		}
+		if account.StaleAfter <= 0 {
+			account.StaleAfter = config.StaleAfter
+		}
+		if account.BalanceInterval <= 0 {
+			account.BalanceInterval = config.BalanceInterval
+		}
+		if account.BalanceTolerance.Cents <= 0 {
+			account.BalanceTolerance = types.USD(100)
+		}
+		if account.ReleaseDigest == "" {
+			account.ReleaseDigest = config.ReleaseDigest
+		}
+
+		if account.Notifier != "" {
+			notifier, ok := config.Notifiers[account.Notifier]
+			if !ok {
+				return fmt.Errorf("%s: unknown notifier %q", account.Label, account.Notifier)
+			}
+			if account.TelegramToken == "" {
+				account.TelegramToken = notifier.TelegramToken
+			}
+			if account.TelegramChatID == "" {
+				account.TelegramChatID = notifier.TelegramChatID
+			}
+		}
+
+		for j := range account.Rules {
+			rule := &account.Rules[j]
+			if rule.Notifier == "" {
+				continue
+			}
+			notifier, ok := config.Notifiers[rule.Notifier]
+			if !ok {
+				return fmt.Errorf("%s: rule %q: unknown notifier %q", account.Label, rule.Name, rule.Notifier)
+			}
+			if rule.TelegramToken == "" {
+				rule.TelegramToken = notifier.TelegramToken
+			}
+			if rule.TelegramChatID == "" {
+				rule.TelegramChatID = notifier.TelegramChatID
+			}
+		}
+
+		// Alerts go to the owner, or to the account's own chat
+		if account.AlertToken == "" {
+			account.AlertToken = owner.TelegramToken
+		}
+		if account.AlertChatID == "" {
+			account.AlertChatID = owner.TelegramChatID
+		}
+		if account.AlertToken == "" {
+			account.AlertToken = account.TelegramToken
+		}
+		if account.AlertChatID == "" {
+			account.AlertChatID = account.TelegramChatID
+		}
+	}
+	return nil
+}
+
+// interpolateEnv replaces ${NAME} in every string value, failing on unset variables
+func interpolateEnv(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		var missing []string
+		result := envPattern.ReplaceAllStringFunc(v, func(match string) string {
+			name := envPattern.FindStringSubmatch(match)[1]
+			env, ok := os.LookupEnv(name)
+			if !ok {
+				missing = append(missing, name)
+			}
+			return env
+		})
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+		}
+		return result, nil
+
+	case map[string]interface{}:
+		for k, item := range v {
+			resolved, err := interpolateEnv(item)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = resolved
+		}
+		return v, nil
+
+	case []interface{}:
+		for i, item := range v {
+			resolved, err := interpolateEnv(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+		return v, nil
+
+	case []map[string]interface{}:
+		// TOML arrays of tables
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			resolved, err := interpolateEnv(item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = resolved
+		}
+		return list, nil
+	}
+	return value, nil
+}
+
+// resolveSecretFiles turns "dmarket_key_file: /run/secrets/dm" into "dmarket_key: <file contents>"
+func resolveSecretFiles(values map[string]interface{}) error {
+	for _, key := range secretFileKeys {
+		path, ok := values[key+"_file"].(string)
+		if !ok || path == "" {
+			continue
+		}
+		if existing, _ := values[key].(string); existing != "" {
+			return fmt.Errorf("both %s and %s_file are set", key, key)
+		}
+		secret, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("%s_file: %v", key, err)
+		}
+		values[key] = strings.TrimSpace(string(secret))
+		delete(values, key+"_file")
+	}
+	return nil
+}
+
+// ValidateConfig checks every account and reports all problems at once
+func ValidateConfig(configs []types.AccountConfig) error {
+	var errs []error
+	labels := make(map[string]bool)
+
+	if len(configs) == 0 {
+		return errors.New("config has no accounts")
+	}
+
+	for i, cfg := range configs {
+		name := cfg.Label
+		if name == "" {
+			name = fmt.Sprintf("account #%d", i+1)
+			errs = append(errs, fmt.Errorf("%s: label is required", name))
+		} else if labels[cfg.Label] {
+			errs = append(errs, fmt.Errorf("%s: duplicate label", name))
+		}
+		labels[cfg.Label] = true
+
+		// Same rule as generateHeaders: 128 hex characters (64-byte ed25519 key)
+		if key, err := hex.DecodeString(cfg.DMarketKey); err != nil || len(key) != 64 {
+			errs = append(errs, fmt.Errorf("%s: dmarket_key must be 128 hex characters (your private API key)", name))
+		}
+
+		if cfg.TelegramToken != "" && !telegramTokenPattern.MatchString(cfg.TelegramToken) {
+			errs = append(errs, fmt.Errorf("%s: telegram_token does not look like \"123456:ABC...\" from @BotFather", name))
+		}
+		if cfg.TelegramToken != "" && !telegramChatIDPattern.MatchString(cfg.TelegramChatID) {
+			errs = append(errs, fmt.Errorf("%s: telegram_chat_id must be numeric (e.g. -100123...) or @channelname", name))
+		}
+
+		if _, ok := parseDigestTime(cfg.ReleaseDigest); !ok && cfg.ReleaseDigest != "" && cfg.ReleaseDigest != "off" {
+			errs = append(errs, fmt.Errorf("%s: release_digest must be \"HH:MM\" (UTC) or \"off\"", name))
+		}
+
+		for j, rule := range cfg.Rules {
+			ruleName := rule.Name
+			if ruleName == "" {
+				ruleName = fmt.Sprintf("#%d", j+1)
+			}
+			for _, err := range validateRule(rule) {
+				errs = append(errs, fmt.Errorf("%s: rule %s: %v", name, ruleName, err))
+			}
+		}
+
+		if cfg.StickerPrices != "" && cfg.StickerPrices != "dmarket" {
+			errs = append(errs, fmt.Errorf("%s: sticker_prices must be \"dmarket\" or empty", name))
+		}
+
+		if cfg.DisplayMode != "" && cfg.DisplayMode != "alongside" && cfg.DisplayMode != "instead" {
+			errs = append(errs, fmt.Errorf("%s: display_mode must be \"alongside\" or \"instead\"", name))
+		}
+		if cfg.DisplayCurrency != "" && cfg.FXURL == "" && cfg.FXFile == "" && len(cfg.FXRates) == 0 &&
+			!strings.EqualFold(cfg.DisplayCurrency, "USD") {
+			errs = append(errs, fmt.Errorf("%s: display_currency needs fx_rates, fx_file or fx_url", name))
+		}
+	}
+
+	return errors.Join(errs...)
+}
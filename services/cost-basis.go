@@ -1,10 +1,12 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
+	"log/slog"
+	"strconv"
 	"sync"
 	"time"
 
@@ -12,47 +14,56 @@ import (
 )
 
 // InitCostBasis loads ALL buy history (DMarket + CSFloat) for all accounts
-func InitCostBasis(configs []types.AccountConfig) (types.CostMap, *sync.RWMutex) {
+func InitCostBasis(ctx context.Context, configs []types.AccountConfig) (types.CostMap, *sync.RWMutex) {
 	
 	// 1. Initialize the Shared Brain
 	costMap := make(types.CostMap)
 	var mu sync.RWMutex
 
-	fmt.Println("Initializing Cost Basis...")
+	slog.Info("Initializing cost basis", "accounts", len(configs))
 
-	// 2. Load DMarket History (Direct Buys)
+	// 2. Load every account into the shared map
 	for _, cfg := range configs {
-		dmCosts, err := FetchDMarketBuyHistory(cfg.DMarketKey) 
-		
-		if err != nil {
-			fmt.Printf("⚠️ Error fetching DMarket history for %s: %v\n", cfg.Label, err)
-		} else {
-			// Write to Shared Map safely
-			mu.Lock()
-			for id, price := range dmCosts {
-				costMap[id] = price
+		LoadAccountCosts(ctx, cfg, costMap, &mu)
+	}
+
+	slog.Info("Cost basis loaded", "items", len(costMap))
+	return costMap, &mu
+}
+
+// LoadAccountCosts adds one account's buys (DMarket + CSFloat) to an existing cost map.
+// Used at startup and when an account is added while running.
+func LoadAccountCosts(ctx context.Context, cfg types.AccountConfig, costMap types.CostMap, mu *sync.RWMutex) {
+	ctx = WithAccount(ctx, cfg.Label)
+
+	// 1. Load DMarket History (Direct Buys)
+	dmCosts, err := FetchDMarketBuyHistory(ctx, cfg.DMarketKey)
+
+	if err != nil {
+		Logger(ctx).Error("Fetching DMarket buy history failed", "error", err)
+	} else {
+		// Write to Shared Map safely
+		mu.Lock()
+		for id, entry := range dmCosts {
+			if costMap[id].Manual {
+				continue
 			}
-			mu.Unlock()
-			fmt.Printf("Loaded %d DMarket buys\n", len(dmCosts))
-		}
-		
-		// 3. Sync CSFloat (If key exists)
-		if cfg.CSFloatKey != "" {
-			SyncCSFloatCosts(cfg, costMap, &mu)
+			costMap[id] = entry
 		}
+		mu.Unlock()
+		Logger(ctx).Info("Loaded DMarket buys", "count", len(dmCosts))
 	}
 
-	fmt.Printf("Total Tracked Items: %d\n", len(costMap))
-	return costMap, &mu
+	// 2. Sync CSFloat (If key exists)
+	if cfg.CSFloatKey != "" {
+		SyncCSFloatCosts(ctx, cfg, costMap, mu)
+	}
 }
 
-func FetchDMarketBuyHistory(secretKey string) (map[string]float64, error) {
-	transactions := make(map[string]float64)
-	method := "GET"
-	rootApiUrl := "https://api.dmarket.com"
-	client := &http.Client{}
+func FetchDMarketBuyHistory(ctx context.Context, secretKey string) (map[string]types.CostEntry, error) {
+	transactions := make(map[string]types.CostEntry)
 
-	fmt.Println("Loading purchase(s) history...")
+	Logger(ctx).Info("Loading purchase history")
 
 	cursor := "" // Start with empty cursor
 	keepFetching := true
@@ -60,27 +71,17 @@ func FetchDMarketBuyHistory(secretKey string) (map[string]float64, error) {
 	for keepFetching {
 		// URL
 		endpoint := fmt.Sprintf("/marketplace-api/v1/user-targets/closed?Limit=500&OrderDir=asc&Status=successful,trade_protected&Cursor=%s", cursor)
-			
-		headers, _ := generateHeaders(secretKey, method, endpoint, nil)
-		req, _ := http.NewRequest(method, rootApiUrl+endpoint, nil)
-		req.Header = headers
 
-		resp, err := client.Do(req)
+		// Rate limited and retried, gives up after a few attempts
+		resp, err := dmarketRequest(ctx, secretKey, "GET", endpoint)
 		if err != nil {
-			time.Sleep(5 * time.Second)
-			continue
+			return nil, err
 		}
 
-		if resp.StatusCode == 429 {
-			resp.Body.Close()
-			time.Sleep(5 * time.Second)
-			continue 
-		}
-			
 		if resp.StatusCode != 200 {
-			body, _ := io.ReadAll(resp.Body)
+			body := errorBody(resp.Body)
 			resp.Body.Close()
-			fmt.Printf("API Error %d: %s\n", resp.StatusCode, string(body))
+			return nil, fmt.Errorf("API Error %d: %s", resp.StatusCode, body)
 		}
 			
 		body, err := io.ReadAll(resp.Body)
@@ -88,7 +89,7 @@ func FetchDMarketBuyHistory(secretKey string) (map[string]float64, error) {
 
 		var response types.UserTargetsClosedResponse
 		if err := json.Unmarshal(body, &response); err != nil {
-			fmt.Printf("Unmarshal error: %v\n", err)
+			Logger(ctx).Error("Decoding purchase history failed", "error", err)
 			break
 		}
 
@@ -96,7 +97,15 @@ func FetchDMarketBuyHistory(secretKey string) (map[string]float64, error) {
 		for _, trade := range response.Trades {
 			if trade.AssetID != "" {
 				// Direct assignment to the map
-				transactions[trade.AssetID] = trade.Price.Amount
+				price, err := types.ParseMoney(trade.Price.Amount.String(), trade.Price.CurrencyCode)
+				if err != nil {
+					Logger(ctx).Warn("Skipping buy with unreadable price", "item", trade.AssetID, "error", err)
+					continue
+				}
+				transactions[trade.AssetID] = types.CostEntry{
+					Price:      price,
+					AcquiredAt: parseTimestamp(trade.ClosedAt),
+				}
 			}
 		}
 
@@ -104,48 +113,59 @@ func FetchDMarketBuyHistory(secretKey string) (map[string]float64, error) {
 		if response.Cursor == "" {
 				keepFetching = false
 		} else {
-			// Update cursor and time sleep
 			cursor = response.Cursor
-			time.Sleep(100 * time.Millisecond)
 		}
 		}
 	
 	return transactions, nil
 }
 
-func FetchCSFloatHistory(apiKey string) (map[string]float64, error) {
-	buyHistory := make(map[string]float64)
-	client := &http.Client{}
-	
+func FetchCSFloatHistory(ctx context.Context, apiKey string) (map[string]types.CostEntry, error) {
+	buyHistory := make(map[string]types.CostEntry)
+
 	// 'verified' and 'pending' trades
-	baseUrl := "https://csfloat.com/api/v1/me/trades?role=buyer&state=verified,pending&limit=1000"
+	trades, err := FetchCSFloatTrades(ctx, apiKey, "buyer", "verified,pending")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, trade := range trades {
+		item := trade.Contract.Item
+
+		// We need both Float and Seed to create a unique fingerprint
+		if item.FloatValue > 0 {
+			buyHistory[fingerprint(item.FloatValue, item.PaintSeed)] = types.CostEntry{
+				Price:      types.USD(int64(trade.Contract.Price)), // CSFloat prices are cents
+				AcquiredAt: csfloatTradeTime(trade),
+			}
+		}
+	}
+
+	return buyHistory, nil
+}
+
+// FetchCSFloatTrades pages through /me/trades for a role ("buyer"/"seller") and comma-separated states
+func FetchCSFloatTrades(ctx context.Context, apiKey, role, states string) ([]types.CSFloatTrade, error) {
+	var trades []types.CSFloatTrade
+
+	baseUrl := fmt.Sprintf("https://csfloat.com/api/v1/me/trades?role=%s&state=%s&limit=1000", role, states)
 	page := 0
-	
-	fmt.Printf("Fetching CSFloat history (Page 0)...")
+
+	Logger(ctx).Info("Fetching CSFloat trades", "role", role)
 
 	for {
 		// 1. Construct URL with pagination
 		url := fmt.Sprintf("%s&page=%d", baseUrl, page)
 		
-		req, _ := http.NewRequest("GET", url, nil)
-		req.Header.Set("Authorization", apiKey)
-
-		resp, err := client.Do(req)
+		resp, err := csfloatRequest(ctx, apiKey, url)
 		if err != nil {
-			return nil, fmt.Errorf("network error: %v", err)
-		}
-
-		if resp.StatusCode == 429 {
-			resp.Body.Close()
-			fmt.Println("\n⚠️ CSFloat Rate Limit. Sleeping 5s...")
-			time.Sleep(5 * time.Second)
-			continue
+			return nil, err
 		}
 
 		if resp.StatusCode != 200 {
-			body, _ := io.ReadAll(resp.Body)
+			body := errorBody(resp.Body)
 			resp.Body.Close()
-			return nil, fmt.Errorf("API Error %d: %s", resp.StatusCode, string(body))
+			return nil, fmt.Errorf("API Error %d: %s", resp.StatusCode, body)
 		}
 
 		body, err := io.ReadAll(resp.Body)
@@ -161,73 +181,55 @@ func FetchCSFloatHistory(apiKey string) (map[string]float64, error) {
 			break
 		}
 
-		// 3. Process Trades
-		for _, trade := range response.Trades {
-			item := trade.Contract.Item
-			
-			// We need both Float and Seed to create a unique fingerprint
-			if item.FloatValue > 0 {
-				// Create Fingerprint: "0.12345678-555"
-				// Must match the format used in Matcher function
-				fingerprint := fmt.Sprintf("%f-%d", item.FloatValue, *item.PaintSeed)
-				
-				// Convert cents to dollars
-				priceUSD := float64(trade.Contract.Price) / 100.0
-				
-				buyHistory[fingerprint] = priceUSD
-			}
-		}
-		
-		fmt.Printf(".") // Progress indicator
+		trades = append(trades, response.Trades...)
+
+		Logger(ctx).Debug("Fetched CSFloat page", "role", role, "page", page, "trades", len(response.Trades))
 		page++
-		time.Sleep(1 * time.Second) // Be polite to API
 	}
 
-	fmt.Println() // New line after dots
-	return buyHistory, nil
+	Logger(ctx).Info("Fetched CSFloat trades", "role", role, "count", len(trades))
+	return trades, nil
 }
 
-func FetchDMarketInventory(secretKey string) ([]types.DMarketInventoryItem, error) {
+// fingerprint identifies a skin across markets, format: "0.011534607969224453-712"
+// Must match between CSFloat trades and DMarket items
+func fingerprint(floatValue float64, paintSeed *int) string {
+	seed := 0
+	if paintSeed != nil {
+		seed = *paintSeed
+	}
+	return fmt.Sprintf("%f-%d", floatValue, seed)
+}
+
+// csfloatTradeTime is when the trade completed, falling back to creation time while pending
+func csfloatTradeTime(trade types.CSFloatTrade) int64 {
+	if ts := parseTimestamp(trade.VerifiedAt); ts != 0 {
+		return ts
+	}
+	return parseTimestamp(trade.CreatedAt)
+}
+
+func FetchDMarketInventory(ctx context.Context, secretKey string) ([]types.DMarketInventoryItem, error) {
 	var inventory []types.DMarketInventoryItem
 	
-	method := "GET"
-	rootApiUrl := "https://api.dmarket.com"
-	client := &http.Client{}
-	
 	cursor := ""
 	keepFetching := true
 	
-	// Privacy logging
-	keyLog := "???"
-	if len(secretKey) > 10 {
-		keyLog = secretKey[len(secretKey)-10:]
-	}
-	fmt.Printf("Fetching DMarket inventory for key ...%s\n", keyLog)
+	Logger(ctx).Info("Fetching DMarket inventory")
 
 	for keepFetching {
 		// Use the correct endpoint for "user offers" (Inventory/On Sale)
 		endpoint := fmt.Sprintf("/exchange/v1/user/offers?side=user&orderBy=price&orderDir=desc&gameId=a8db&limit=100&currency=USD&cursor=%s", cursor)
-		
-		headers, _ := generateHeaders(secretKey, method, endpoint, nil)
-		req, _ := http.NewRequest(method, rootApiUrl+endpoint, nil)
-		req.Header = headers
 
-		resp, err := client.Do(req)
+		resp, err := dmarketRequest(ctx, secretKey, "GET", endpoint)
 		if err != nil {
-			time.Sleep(2 * time.Second)
-			continue
-		}
-
-		if resp.StatusCode == 429 {
-			resp.Body.Close()
-			time.Sleep(5 * time.Second)
-			continue
+			return nil, err
 		}
 
 		if resp.StatusCode != 200 {
-			body, _ := io.ReadAll(resp.Body)
+			body := errorBody(resp.Body)
 			resp.Body.Close()
-			return nil, fmt.Errorf("API Error %d: %s", resp.StatusCode, string(body))
+			return nil, fmt.Errorf("API Error %d: %s", resp.StatusCode, body)
 		}
 
 		body, err := io.ReadAll(resp.Body)
@@ -246,8 +248,6 @@ func FetchDMarketInventory(secretKey string) ([]types.DMarketInventoryItem, erro
 			keepFetching = false
 		} else {
 			cursor = response.Cursor
-			// Slight delay
-			time.Sleep(100 * time.Millisecond)
 		}
 	}
 
@@ -255,20 +255,21 @@ func FetchDMarketInventory(secretKey string) ([]types.DMarketInventoryItem, erro
 }
 
 // SyncCSFloatCosts fetches CSFloat history and matches it with DMarket inventory
-func SyncCSFloatCosts(cfg types.AccountConfig, costs types.CostMap, mu *sync.RWMutex) {
-	fmt.Printf("Syncing CSFloat Buys for %s...\n", cfg.Label)
+func SyncCSFloatCosts(ctx context.Context, cfg types.AccountConfig, costs types.CostMap, mu *sync.RWMutex) {
+	ctx = WithAccount(ctx, cfg.Label)
+	Logger(ctx).Info("Syncing CSFloat buys")
 	
 	// 1. Fetch CSFloat Buy History (Map of "Float-Seed" -> Price)
-	csfloatBuys, err := FetchCSFloatHistory(cfg.CSFloatKey)
+	csfloatBuys, err := FetchCSFloatHistory(ctx, cfg.CSFloatKey)
 	if err != nil {
-		fmt.Printf("Error fetching CSFloat history: %v\n", err)
+		Logger(ctx).Error("Fetching CSFloat history failed", "error", err)
 		return
 	}
 
 	// 2. Fetch Current DMarket Inventory (To get ItemIDs)
-	inventory, err := FetchDMarketInventory(cfg.DMarketKey)
+	inventory, err := FetchDMarketInventory(ctx, cfg.DMarketKey)
 	if err != nil {
-		fmt.Printf("Error fetching DMarket inventory: %v\n", err)
+		Logger(ctx).Error("Fetching DMarket inventory failed", "error", err)
 		return
 	}
 
@@ -283,21 +284,28 @@ func SyncCSFloatCosts(cfg types.AccountConfig, costs types.CostMap, mu *sync.RWM
 			continue
 		}
 
-		seed := 0
-        if item.Extra.PaintSeed != nil {
-            seed = *item.Extra.PaintSeed
-        }
-
-		// Create the "Fingerprint", format: "0.011534607969224453-712"
-		fingerprint := fmt.Sprintf("%f-%d", item.Extra.FloatValue, seed)
-
 		// Check if we have a buy record for this fingerprint
-		if price, found := csfloatBuys[fingerprint]; found {
+		if entry, found := csfloatBuys[fingerprint(item.Extra.FloatValue, item.Extra.PaintSeed)]; found && !costs[item.ItemID].Manual {
 			// We map the DMarket ItemID (from inventory) to the Price (from CSFloat)
-			costs[item.ItemID] = price 
+			costs[item.ItemID] = entry
 			matches++
 		}
 	}
 	
-	fmt.Printf("Matched %d CSFloat items to DMarket Inventory\n", matches)
-}
\ No newline at end of file
+	Logger(ctx).Info("Matched CSFloat buys to DMarket inventory", "matches", matches)
+	csfloatMatches.WithLabelValues(cfg.Label).Set(float64(matches))
+}
+
+// parseTimestamp accepts unix seconds ("1700000000") or RFC3339, returns 0 if empty/invalid
+func parseTimestamp(value string) int64 {
+	if value == "" {
+		return 0
+	}
+	if ts, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return ts
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Unix()
+	}
+	return 0
+}
@@ -0,0 +1,324 @@
+package services
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+)
+
+// Static files of the dashboard, built into the binary
+//
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// Transactions returned by /api/transactions
+const (
+	dashboardDefaultLimit = 100
+	dashboardMaxLimit     = 1000
+)
+
+// dashboard answers the JSON API from the ledger, only for the running accounts
+type dashboard struct {
+	accounts func() []types.AccountConfig
+	shared   *Shared
+}
+
+// DashboardHandler serves the web dashboard and its JSON API, read from the ledger:
+//
+//	/                  the page (HTML, JS, CSS)
+//	/api/accounts      per-account balance, sells, cost-basis coverage and realized profit
+//	/api/balance       balance snapshots (or the balance after every transaction), per account (?account=)
+//	/api/pnl           daily realized profit (?account=&days=30)
+//	/api/transactions  newest first (?account=&q=&float=&seed=&type=&since=&limit=100)
+//
+// An empty ?account= means all running accounts.
+func DashboardHandler(accounts func() []types.AccountConfig, shared *Shared) http.Handler {
+	d := &dashboard{accounts: accounts, shared: shared}
+	static, _ := fs.Sub(dashboardFiles, "dashboard")
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /", http.FileServerFS(static))
+	mux.HandleFunc("GET /api/accounts", d.serveAccounts)
+	mux.HandleFunc("GET /api/balance", d.serveBalance)
+	mux.HandleFunc("GET /api/pnl", d.servePnL)
+	mux.HandleFunc("GET /api/transactions", d.serveTransactions)
+	return mux
+}
+
+func (d *dashboard) serveAccounts(w http.ResponseWriter, r *http.Request) {
+	summaries := []types.DashboardAccount{}
+	for _, cfg := range d.accounts() {
+		summaries = append(summaries, SummarizeAccount(cfg, d.shared.Ledger.Entries(cfg.Label)))
+	}
+
+	d.shared.CostMu.RLock()
+	costItems := len(d.shared.Costs)
+	d.shared.CostMu.RUnlock()
+
+	writeJSON(w, struct {
+		Accounts       []types.DashboardAccount `json:"accounts"`
+		CostBasisItems int                      `json:"costBasisItems"` // Shared by all accounts
+	}{summaries, costItems})
+}
+
+func (d *dashboard) serveBalance(w http.ResponseWriter, r *http.Request) {
+	entries, ok := d.entries(w, r)
+	if !ok {
+		return
+	}
+	history := BalanceHistory(entries)
+
+	// Scheduled snapshots are denser and carry the trade-protected balance, prefer them
+	label := r.URL.Query().Get("account")
+	for _, cfg := range d.accounts() {
+		if label != "" && cfg.Label != label {
+			continue
+		}
+		if snapshots := d.shared.Balances.History(cfg.Label, 0); len(snapshots) > 0 {
+			history[cfg.Label] = SnapshotPoints(snapshots)
+		}
+	}
+	writeJSON(w, history)
+}
+
+func (d *dashboard) servePnL(w http.ResponseWriter, r *http.Request) {
+	entries, ok := d.entries(w, r)
+	if !ok {
+		return
+	}
+	days, err := queryInt(r, "days", 30)
+	if err != nil || days < 1 || days > 3650 {
+		http.Error(w, "invalid days (1-3650)", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, DailyRealizedPnL(entries, days, time.Now(), d.rates))
+}
+
+func (d *dashboard) serveTransactions(w http.ResponseWriter, r *http.Request) {
+	entries, ok := d.entries(w, r)
+	if !ok {
+		return
+	}
+	limit, err := queryInt(r, "limit", dashboardDefaultLimit)
+	if err != nil || limit < 1 {
+		http.Error(w, "invalid limit", http.StatusBadRequest)
+		return
+	}
+	filter, err := parseEntryFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, filter.newest(entries, min(limit, dashboardMaxLimit)))
+}
+
+// rates is the FX source of a running account (nil if it has none or isn't running)
+func (d *dashboard) rates(account string) RateProvider {
+	for _, cfg := range d.accounts() {
+		if cfg.Label == account {
+			return RateProviderFor(cfg)
+		}
+	}
+	return nil
+}
+
+// entries returns the ledger entries of ?account= (all running accounts if empty), oldest first
+func (d *dashboard) entries(w http.ResponseWriter, r *http.Request) ([]types.LedgerEntry, bool) {
+	label := r.URL.Query().Get("account")
+
+	running := make(map[string]bool)
+	for _, cfg := range d.accounts() {
+		running[cfg.Label] = true
+	}
+	if label != "" && !running[label] {
+		http.Error(w, "unknown account", http.StatusNotFound)
+		return nil, false
+	}
+
+	var entries []types.LedgerEntry
+	for _, entry := range d.shared.Ledger.Entries(label) {
+		if running[entry.Account] {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, true
+}
+
+// SummarizeAccount computes the dashboard totals of one account from its ledger entries (oldest first).
+// Deposits and profits are summed in USD.
+func SummarizeAccount(cfg types.AccountConfig, entries []types.LedgerEntry) types.DashboardAccount {
+	summary := types.DashboardAccount{Label: cfg.Label, Transactions: len(entries)}
+	rates := RateProviderFor(cfg)
+
+	for _, entry := range entries {
+		summary.Balance = entry.Balance
+		summary.LastTx = entry.Time
+
+		if entry.Flow == FlowCapital && entry.Status != "reverted" {
+			summary.NetDeposits = addUSD(summary.NetDeposits, entry.Capital, rates)
+		}
+		if entry.Action != "Sell" || entry.Status == "reverted" {
+			continue
+		}
+		summary.Sells++
+		if entry.BuyPrice.Cents > 0 {
+			summary.SellsWithCost++
+			summary.Realized = addUSD(summary.Realized, entry.Profit, rates)
+		}
+	}
+	return summary
+}
+
+// BalanceHistory returns the balance after every transaction, per account (entries oldest first)
+func BalanceHistory(entries []types.LedgerEntry) map[string][]types.BalancePoint {
+	history := make(map[string][]types.BalancePoint)
+	for _, entry := range entries {
+		if entry.Balance.Currency == "" && entry.Balance.Cents == 0 {
+			// Balance missing in the transaction (older ledger lines)
+			continue
+		}
+		history[entry.Account] = append(history[entry.Account], types.BalancePoint{Time: entry.Time, Balance: entry.Balance})
+	}
+	return history
+}
+
+// SnapshotPoints turns balance snapshots into chart points (available balance)
+func SnapshotPoints(snapshots []types.BalanceSnapshot) []types.BalancePoint {
+	points := make([]types.BalancePoint, len(snapshots))
+	for i, snapshot := range snapshots {
+		points[i] = types.BalancePoint{Time: snapshot.Time, Balance: snapshot.Available, TradeProtected: snapshot.TradeProtected}
+	}
+	return points
+}
+
+// DailyRealizedPnL sums the profit of sells with a known buy price per local day,
+// for the last days days up to now (days without sells are included as zero), in USD with the rates of each entry's account
+func DailyRealizedPnL(entries []types.LedgerEntry, days int, now time.Time, rates func(account string) RateProvider) []types.DailyPnL {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	first := today.AddDate(0, 0, -(days - 1))
+
+	result := make([]types.DailyPnL, days)
+	index := make(map[string]int, days)
+	for i := range result {
+		date := first.AddDate(0, 0, i).Format("2006-01-02")
+		result[i] = types.DailyPnL{Date: date, Profit: types.USD(0)}
+		index[date] = i
+	}
+
+	for _, entry := range entries {
+		if entry.Action != "Sell" || entry.Status == "reverted" || entry.BuyPrice.Cents <= 0 {
+			continue
+		}
+		i, ok := index[time.Unix(entry.Time, 0).In(now.Location()).Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+		result[i].Profit = addUSD(result[i].Profit, entry.Profit, rates(entry.Account))
+		result[i].Sells++
+	}
+	return result
+}
+
+// entryFilter is the search of /api/transactions (and /transactions of the REST API), empty fields match everything
+type entryFilter struct {
+	title  string // Case-insensitive substring
+	float  string // Prefix of the float value, "0.01" matches 0.0123...
+	seed   *int   // Paint seed (pattern)
+	action string // Transaction type or action, e.g. "sell", "target_closed"
+	since  int64  // Unix seconds
+}
+
+func parseEntryFilter(r *http.Request) (entryFilter, error) {
+	query := r.URL.Query()
+	filter := entryFilter{
+		title:  strings.ToLower(strings.TrimSpace(query.Get("q"))),
+		float:  strings.TrimSpace(query.Get("float")),
+		action: strings.TrimSpace(query.Get("type")),
+	}
+	if filter.float != "" {
+		if _, err := strconv.ParseFloat(filter.float, 64); err != nil {
+			return filter, fmt.Errorf("invalid float %q", filter.float)
+		}
+	}
+	if raw := strings.TrimSpace(query.Get("seed")); raw != "" {
+		seed, err := strconv.Atoi(raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid seed %q", raw)
+		}
+		filter.seed = &seed
+	}
+	if raw := strings.TrimSpace(query.Get("since")); raw != "" {
+		since, err := parseSince(raw)
+		if err != nil {
+			return filter, err
+		}
+		filter.since = since.Unix()
+	}
+	return filter, nil
+}
+
+// parseSince accepts 2006-01-02 (local time), RFC3339 or Unix seconds
+func parseSince(raw string) (time.Time, error) {
+	if unix, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", raw, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid since %q (2006-01-02, RFC3339 or Unix seconds)", raw)
+}
+
+func (f entryFilter) match(entry types.LedgerEntry) bool {
+	if f.title != "" && !strings.Contains(strings.ToLower(entry.Title), f.title) {
+		return false
+	}
+	if f.float != "" && (entry.Float == 0 || !strings.HasPrefix(strconv.FormatFloat(entry.Float, 'f', -1, 64), f.float)) {
+		return false
+	}
+	if f.seed != nil && (entry.PaintSeed == nil || *entry.PaintSeed != *f.seed) {
+		return false
+	}
+	if f.action != "" && !strings.EqualFold(entry.Type, f.action) && !strings.EqualFold(entry.Action, f.action) {
+		return false
+	}
+	if entry.Time < f.since {
+		return false
+	}
+	return true
+}
+
+// newest returns up to limit matching entries, newest first (entries oldest first)
+func (f entryFilter) newest(entries []types.LedgerEntry, limit int) []types.LedgerEntry {
+	result := []types.LedgerEntry{}
+	for i := len(entries) - 1; i >= 0 && len(result) < limit; i-- {
+		if f.match(entries[i]) {
+			result = append(result, entries[i])
+		}
+	}
+	return result
+}
+
+// queryInt reads an integer query parameter, fallback if it is missing
+func queryInt(r *http.Request, name string, fallback int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(raw)
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
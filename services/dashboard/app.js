@@ -0,0 +1,203 @@
+// Dashboard page: loads the JSON API of the tracker and draws plain SVG charts (no external libraries)
+"use strict";
+
+const colors = ["#58a6ff", "#d29922", "#a371f7", "#3fb950", "#f78166", "#39c5cf"];
+const svgNS = "http://www.w3.org/2000/svg";
+
+const accountSelect = document.getElementById("account");
+const daysSelect = document.getElementById("days");
+const searchForm = document.getElementById("search");
+
+async function api(path, params = {}) {
+  const query = new URLSearchParams();
+  for (const [key, value] of Object.entries(params)) {
+    if (value !== "" && value !== undefined) query.set(key, value);
+  }
+  const resp = await fetch(`api/${path}?${query}`);
+  if (!resp.ok) throw new Error(`${path}: ${resp.status} ${await resp.text()}`);
+  return resp.json();
+}
+
+// Money is {"amount":"12.34","currency":"USD"}
+const amount = (money) => (money ? parseFloat(money.amount) : 0);
+const formatMoney = (money) => (money ? `${money.amount} ${money.currency === "USD" ? "$" : money.currency}` : "");
+const formatDate = (unix) => (unix ? new Date(unix * 1000).toLocaleString() : "");
+const signClass = (value) => (value > 0 ? "profit" : value < 0 ? "loss" : "");
+
+function cell(row, text, className = "") {
+  const td = row.insertCell();
+  td.textContent = text;
+  if (className) td.className = className;
+  return td;
+}
+
+function svgElement(parent, name, attrs, text) {
+  const el = document.createElementNS(svgNS, name);
+  for (const [key, value] of Object.entries(attrs)) el.setAttribute(key, value);
+  if (text !== undefined) el.textContent = text;
+  parent.appendChild(el);
+  return el;
+}
+
+// Chart area inside the 600x240 viewBox, leaving room for the axis labels
+const chart = { left: 56, right: 592, top: 8, bottom: 220 };
+
+function scale(min, max, from, to) {
+  if (max === min) max = min + 1;
+  return (value) => from + ((value - min) / (max - min)) * (to - from);
+}
+
+function drawAxis(svg, min, max, y) {
+  for (let i = 0; i <= 4; i++) {
+    const value = min + ((max - min) * i) / 4;
+    const py = y(value);
+    svgElement(svg, "line", { x1: chart.left, x2: chart.right, y1: py, y2: py, class: "grid" });
+    svgElement(svg, "text", { x: chart.left - 6, y: py + 4, "text-anchor": "end" }, value.toFixed(2));
+  }
+}
+
+function drawBalance(history) {
+  const svg = document.getElementById("balance-chart");
+  const legend = document.getElementById("balance-legend");
+  svg.replaceChildren();
+  legend.replaceChildren();
+
+  const series = Object.entries(history);
+  const points = series.flatMap(([, list]) => list);
+  if (points.length === 0) {
+    svgElement(svg, "text", { x: 300, y: 120, "text-anchor": "middle" }, "No transactions in the ledger yet");
+    return;
+  }
+
+  const times = points.map((p) => p.time);
+  const values = points.map((p) => amount(p.balance));
+  const x = scale(Math.min(...times), Math.max(...times), chart.left, chart.right);
+  const minValue = Math.min(...values), maxValue = Math.max(...values);
+  const y = scale(minValue, maxValue, chart.bottom, chart.top);
+
+  drawAxis(svg, minValue, maxValue, y);
+  svgElement(svg, "text", { x: chart.left, y: 236 }, new Date(Math.min(...times) * 1000).toLocaleDateString());
+  svgElement(svg, "text", { x: chart.right, y: 236, "text-anchor": "end" }, new Date(Math.max(...times) * 1000).toLocaleDateString());
+
+  series.forEach(([label, list], i) => {
+    const color = colors[i % colors.length];
+    const path = list.map((p) => `${x(p.time).toFixed(1)},${y(amount(p.balance)).toFixed(1)}`).join(" ");
+    svgElement(svg, "polyline", { points: path, fill: "none", stroke: color, "stroke-width": 2, "vector-effect": "non-scaling-stroke" });
+
+    const item = document.createElement("span");
+    item.style.setProperty("--color", color);
+    item.textContent = `${label}: ${formatMoney(list[list.length - 1].balance)}`;
+    legend.appendChild(item);
+  });
+}
+
+function drawPnL(days) {
+  const svg = document.getElementById("pnl-chart");
+  svg.replaceChildren();
+
+  const values = days.map((d) => amount(d.profit));
+  const minValue = Math.min(0, ...values), maxValue = Math.max(0, ...values);
+  const y = scale(minValue, maxValue, chart.bottom, chart.top);
+  const width = (chart.right - chart.left) / days.length;
+
+  drawAxis(svg, minValue, maxValue, y);
+  svgElement(svg, "line", { x1: chart.left, x2: chart.right, y1: y(0), y2: y(0), class: "zero" });
+
+  days.forEach((day, i) => {
+    const value = values[i];
+    const bar = svgElement(svg, "rect", {
+      x: chart.left + i * width + width * 0.1,
+      y: Math.min(y(value), y(0)),
+      width: width * 0.8,
+      height: Math.abs(y(value) - y(0)),
+      fill: value >= 0 ? "var(--profit)" : "var(--loss)",
+    });
+    svgElement(bar, "title", {}, `${day.date}: ${formatMoney(day.profit)} (${day.sells} sells)`);
+  });
+  svgElement(svg, "text", { x: chart.left, y: 236 }, days[0].date);
+  svgElement(svg, "text", { x: chart.right, y: 236, "text-anchor": "end" }, days[days.length - 1].date);
+
+  const total = values.reduce((sum, v) => sum + v, 0);
+  const sells = days.reduce((sum, d) => sum + d.sells, 0);
+  const note = document.getElementById("pnl-total");
+  note.textContent = `${sells} sells with a known buy price, realized ${total.toFixed(2)}`;
+  note.className = `note ${signClass(total)}`;
+}
+
+function fillAccounts(data) {
+  const body = document.querySelector("#accounts tbody");
+  body.replaceChildren();
+
+  for (const account of data.accounts) {
+    if (![...accountSelect.options].some((o) => o.value === account.label)) {
+      accountSelect.add(new Option(account.label, account.label));
+    }
+
+    const row = body.insertRow();
+    const coverage = account.sells > 0 ? `${((account.sellsWithCost / account.sells) * 100).toFixed(0)}% (${account.sellsWithCost}/${account.sells})` : "-";
+    cell(row, account.label);
+    cell(row, formatMoney(account.balance), "num");
+    cell(row, account.transactions, "num");
+    cell(row, account.sells, "num");
+    cell(row, coverage, "num");
+    cell(row, formatMoney(account.realized), `num ${signClass(amount(account.realized))}`);
+    cell(row, formatMoney(account.netDeposits), "num");
+    cell(row, formatDate(account.lastTx));
+  }
+  document.getElementById("cost-items").textContent = `${data.costBasisItems} items with a known buy price (all accounts)`;
+}
+
+function fillTransactions(entries) {
+  const body = document.querySelector("#transactions tbody");
+  body.replaceChildren();
+
+  if (entries.length === 0) {
+    cell(body.insertRow(), "Nothing found", "note").colSpan = 10;
+    return;
+  }
+  for (const entry of entries) {
+    const row = body.insertRow();
+    cell(row, formatDate(entry.time));
+    cell(row, entry.account);
+    cell(row, entry.flow ? entry.type : entry.action || entry.type);
+    cell(row, entry.title, "item");
+    cell(row, entry.float ? entry.float.toFixed(6) : "", "num");
+    cell(row, entry.paintSeed ?? "", "num");
+    cell(row, formatMoney(entry.flow === "capital" ? entry.capital : entry.amount), "num");
+    cell(row, formatMoney(entry.buyPrice), "num");
+    cell(row, formatMoney(entry.profit), `num ${signClass(amount(entry.profit))}`);
+    cell(row, entry.status);
+  }
+}
+
+async function loadTransactions() {
+  const search = Object.fromEntries(new FormData(searchForm));
+  fillTransactions(await api("transactions", { account: accountSelect.value, ...search }));
+}
+
+async function loadPnL() {
+  drawPnL(await api("pnl", { account: accountSelect.value, days: daysSelect.value }));
+}
+
+async function loadAll() {
+  try {
+    const account = accountSelect.value;
+    fillAccounts(await api("accounts"));
+    drawBalance(await api("balance", { account }));
+    await loadPnL();
+    await loadTransactions();
+  } catch (err) {
+    console.error(err);
+  }
+}
+
+accountSelect.addEventListener("change", loadAll);
+daysSelect.addEventListener("change", () => loadPnL().catch(console.error));
+searchForm.addEventListener("submit", (event) => {
+  event.preventDefault();
+  loadTransactions().catch(console.error);
+});
+searchForm.addEventListener("reset", () => setTimeout(() => loadTransactions().catch(console.error)));
+
+loadAll();
+setInterval(loadAll, 60000);
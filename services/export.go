@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+)
+
+// ExportAccountLedger downloads the full DMarket history (and CSFloat trades if configured) and joins it
+func ExportAccountLedger(ctx context.Context, cfg types.AccountConfig) ([]types.ExportRow, error) {
+	ctx = WithAccount(ctx, cfg.Label)
+	Logger(ctx).Info("Fetching full history")
+	history, err := FetchFullHistory(ctx, cfg.DMarketKey, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var csBuys, csSells []types.CSFloatTrade
+	if cfg.CSFloatKey != "" {
+		if csBuys, err = FetchCSFloatTrades(ctx, cfg.CSFloatKey, "buyer", "verified"); err != nil {
+			return nil, err
+		}
+		if csSells, err = FetchCSFloatTrades(ctx, cfg.CSFloatKey, "seller", "verified"); err != nil {
+			return nil, err
+		}
+	}
+
+	return BuildTradeLedger(cfg.Label, history, csBuys, csSells), nil
+}
+
+// exportLeg is a DMarket transaction or CSFloat trade on a common timeline
+type exportLeg struct {
+	time int64
+	row  types.ExportRow
+	sell bool
+	buy  bool
+}
+
+// BuildTradeLedger joins buy and sell legs of one account chronologically.
+// A sell is matched to the latest earlier buy of the same DMarket item ID, or of the same float/seed fingerprint.
+func BuildTradeLedger(account string, history []types.Transaction, csBuys, csSells []types.CSFloatTrade) []types.ExportRow {
+	var legs []exportLeg
+
+	// 1. DMarket legs
+	for _, tx := range history {
+		var amount types.Money
+		if len(tx.Changes) > 0 {
+			amount = tx.Changes[0].Money.Abs()
+		}
+		isSell := tx.Action == "Sell" || tx.Type == "instant_sell"
+		isBuy := tx.Type == "purchase" || tx.Type == "target_closed"
+		if !moneyIn(tx) {
+			amount = amount.Neg()
+		}
+
+		legs = append(legs, exportLeg{
+			time: tx.CreatedAt,
+			sell: isSell,
+			buy:  isBuy,
+			row: types.ExportRow{
+				Account:   account,
+				Source:    "dmarket",
+				TxID:      tx.ID,
+				Type:      tx.Type,
+				Status:    tx.Status,
+				Item:      tx.Subject,
+				ItemID:    tx.Details.ItemID,
+				Float:     tx.Details.Extra.FloatValue,
+				PaintSeed: tx.Details.Extra.PaintSeed,
+				Amount:    amount,
+			},
+		})
+	}
+
+	// 2. CSFloat legs
+	for _, trade := range csBuys {
+		legs = append(legs, csfloatLeg(account, trade, false))
+	}
+	for _, trade := range csSells {
+		legs = append(legs, csfloatLeg(account, trade, true))
+	}
+
+	sort.SliceStable(legs, func(i, j int) bool { return legs[i].time < legs[j].time })
+
+	// 3. Walk the timeline, remembering the latest buy per item
+	byItemID := make(map[string]exportLeg)
+	byFingerprint := make(map[string]exportLeg)
+	rows := make([]types.ExportRow, 0, len(legs))
+
+	for _, leg := range legs {
+		row := leg.row
+		row.Date = time.Unix(leg.time, 0).UTC().Format(time.RFC3339)
+		key := ""
+		if row.Float > 0 {
+			key = fingerprint(row.Float, row.PaintSeed)
+		}
+
+		switch {
+		case leg.buy && row.Status != "reverted":
+			if row.ItemID != "" {
+				byItemID[row.ItemID] = leg
+			}
+			if key != "" {
+				byFingerprint[key] = leg
+			}
+
+		case leg.sell:
+			row.SellPrice = row.Amount
+			if row.Status == "reverted" {
+				break
+			}
+			if row.Source == "dmarket" {
+				// Same estimate the tracker deducts from the balance in messages
+				row.Fee = sellFee(row.SellPrice)
+			}
+
+			buy, found := byItemID[row.ItemID]
+			if !found && key != "" {
+				buy, found = byFingerprint[key]
+			}
+			if found {
+				// A buy leg is used by one sell only
+				delete(byItemID, buy.row.ItemID)
+				if key != "" {
+					delete(byFingerprint, key)
+				}
+
+				row.BuyDate = time.Unix(buy.time, 0).UTC().Format(time.RFC3339)
+				row.BuyPrice = buy.row.Amount.Neg()
+				// Before fees, like the ledger and the dashboard (the fee column shows the estimate).
+				// Legs in different currencies are left for the reader to convert.
+				if row.SellPrice.CurrencyCode() == row.BuyPrice.CurrencyCode() {
+					row.RealizedGain = row.SellPrice.Sub(row.BuyPrice)
+				}
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+func csfloatLeg(account string, trade types.CSFloatTrade, sell bool) exportLeg {
+	item := trade.Contract.Item
+	amount := types.USD(int64(trade.Contract.Price)) // CSFloat prices are cents
+	tradeType := "csfloat_buy"
+	if sell {
+		tradeType = "csfloat_sell"
+	} else {
+		amount = amount.Neg()
+	}
+
+	return exportLeg{
+		time: csfloatTradeTime(trade),
+		sell: sell,
+		buy:  !sell,
+		row: types.ExportRow{
+			Account:   account,
+			Source:    "csfloat",
+			TxID:      trade.ID,
+			Type:      tradeType,
+			Status:    "success",
+			Item:      item.MarketName,
+			Float:     item.FloatValue,
+			PaintSeed: item.PaintSeed,
+			Amount:    amount,
+		},
+	}
+}
+
+// moneyIn reports whether the transaction adds money to the balance (by activity type, like ActivityFlow)
+func moneyIn(tx types.Transaction) bool {
+	switch tx.Type {
+	case "sell", "instant_sell", "deposit", "refund":
+		return true
+	}
+	return false
+}
+
+// FilterExportRows keeps rows dated within [from, to) (zero = unbounded)
+func FilterExportRows(rows []types.ExportRow, from, to time.Time) []types.ExportRow {
+	var filtered []types.ExportRow
+	for _, row := range rows {
+		date, _ := time.Parse(time.RFC3339, row.Date)
+		if !from.IsZero() && date.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !date.Before(to) {
+			continue
+		}
+		filtered = append(filtered, row)
+	}
+	return filtered
+}
+
+// WriteExportCSV writes rows as CSV. Excel mode adds a UTF-8 BOM and CRLF line endings so
+// spreadsheet apps (Excel, LibreOffice, Google Sheets) open it with the right encoding.
+func WriteExportCSV(w io.Writer, rows []types.ExportRow, excel bool) error {
+	if excel {
+		if _, err := w.Write([]byte("\xEF\xBB\xBF")); err != nil {
+			return err
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	writer.UseCRLF = excel
+
+	header := []string{"date", "account", "source", "tx_id", "type", "status", "item", "item_id", "float", "paint_seed",
+		"amount", "buy_date", "buy_price", "sell_price", "fee", "realized_gain"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		seed := ""
+		if row.PaintSeed != nil {
+			seed = strconv.Itoa(*row.PaintSeed)
+		}
+		floatStr := ""
+		if row.Float > 0 {
+			floatStr = strconv.FormatFloat(row.Float, 'f', -1, 64)
+		}
+
+		record := []string{row.Date, row.Account, row.Source, row.TxID, row.Type, row.Status, row.Item, row.ItemID,
+			floatStr, seed, row.Amount.String(), row.BuyDate, optionalMoney(row.BuyPrice), optionalMoney(row.SellPrice),
+			optionalMoney(row.Fee), optionalMoney(row.RealizedGain)}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteExportJSON writes rows as an indented JSON array
+func WriteExportJSON(w io.Writer, rows []types.ExportRow) error {
+	if rows == nil {
+		rows = []types.ExportRow{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(rows)
+}
+
+func optionalMoney(value types.Money) string {
+	if value.IsZero() {
+		return ""
+	}
+	return value.String()
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+)
+
+// RateProvider returns how many units of a currency one USD buys ("EUR" -> 0.92)
+type RateProvider interface {
+	USDRate(currency string) (float64, error)
+}
+
+// StaticRates is a fixed table from the config
+type StaticRates map[string]float64
+
+func (r StaticRates) USDRate(currency string) (float64, error) {
+	if rate, ok := r[strings.ToUpper(currency)]; ok && rate > 0 {
+		return rate, nil
+	}
+	return 0, fmt.Errorf("no FX rate for %s", currency)
+}
+
+// FileRates reads a JSON table ({"EUR": 0.92, "UAH": 41.3}) and re-reads it when the file changes
+type FileRates struct {
+	path    string
+	mu      sync.Mutex
+	modTime time.Time
+	rates   StaticRates
+}
+
+func (r *FileRates) USDRate(currency string) (float64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return 0, err
+	}
+	if r.rates == nil || info.ModTime() != r.modTime {
+		data, err := os.ReadFile(r.path)
+		if err != nil {
+			return 0, err
+		}
+		var rates StaticRates
+		if err := json.Unmarshal(data, &rates); err != nil {
+			return 0, fmt.Errorf("FX file %s: %v", r.path, err)
+		}
+		r.rates = upperKeys(rates)
+		r.modTime = info.ModTime()
+	}
+	return r.rates.USDRate(currency)
+}
+
+// HTTPRates fetches USD-based rates from a URL returning {"rates": {"EUR": 0.92, ...}}
+// (e.g. https://open.er-api.com/v6/latest/USD) and caches them for ttl.
+// Refreshes run in the background, callers never wait for the FX source.
+type HTTPRates struct {
+	url        string
+	ttl        time.Duration
+	mu         sync.Mutex
+	fetchedAt  time.Time
+	rates      StaticRates
+	refreshing bool
+}
+
+func (r *HTTPRates) USDRate(currency string) (float64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.refreshing && (r.rates == nil || time.Since(r.fetchedAt) > r.ttl) {
+		r.refreshing = true
+		go r.refresh()
+	}
+	if r.rates == nil {
+		return 0, fmt.Errorf("FX rates from %s not loaded yet", r.url)
+	}
+	return r.rates.USDRate(currency)
+}
+
+// refresh fetches the rates without holding the lock, stale rates are kept when it fails
+func (r *HTTPRates) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	rates, err := fetchRates(ctx, r.url)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.refreshing = false
+	r.fetchedAt = time.Now()
+	if err != nil {
+		// Serve stale rates rather than nothing
+		slog.Warn("FX refresh failed, using cached rates", "url", r.url, "error", err)
+		return
+	}
+	r.rates = rates
+}
+
+func fetchRates(ctx context.Context, url string) (StaticRates, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := doRequest(ctx, client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("FX API status %d", resp.StatusCode)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	var response struct {
+		Rates StaticRates `json:"rates"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	return upperKeys(response.Rates), nil
+}
+
+func upperKeys(rates StaticRates) StaticRates {
+	result := make(StaticRates, len(rates))
+	for k, v := range rates {
+		result[strings.ToUpper(k)] = v
+	}
+	return result
+}
+
+// Providers are shared between accounts using the same source (one HTTP cache per URL)
+var (
+	fxMu        sync.Mutex
+	fxProviders = make(map[string]RateProvider)
+)
+
+// RateProviderFor returns the account's FX source: fx_url, then fx_file, then fx_rates. Nil if none.
+func RateProviderFor(cfg types.AccountConfig) RateProvider {
+	fxMu.Lock()
+	defer fxMu.Unlock()
+
+	switch {
+	case cfg.FXURL != "":
+		key := "url:" + cfg.FXURL
+		if p, ok := fxProviders[key]; ok {
+			return p
+		}
+		ttl := time.Duration(cfg.FXCacheMinutes) * time.Minute
+		if ttl <= 0 {
+			ttl = time.Hour
+		}
+		// Load the rates right away, so the first message already has them
+		p := &HTTPRates{url: cfg.FXURL, ttl: ttl, refreshing: true}
+		go p.refresh()
+		fxProviders[key] = p
+		return p
+
+	case cfg.FXFile != "":
+		key := "file:" + cfg.FXFile
+		if p, ok := fxProviders[key]; ok {
+			return p
+		}
+		p := &FileRates{path: cfg.FXFile}
+		fxProviders[key] = p
+		return p
+
+	case len(cfg.FXRates) > 0:
+		return upperKeys(cfg.FXRates)
+	}
+	return nil
+}
+
+// ConvertMoney converts between currencies through their USD rates
+func ConvertMoney(m types.Money, to string, rates RateProvider) (types.Money, error) {
+	from := m.CurrencyCode()
+	to = strings.ToUpper(to)
+	if from == to {
+		return m, nil
+	}
+	if rates == nil {
+		return m, fmt.Errorf("no FX source configured for %s -> %s", from, to)
+	}
+
+	fromRate := 1.0
+	if from != "USD" {
+		rate, err := rates.USDRate(from)
+		if err != nil {
+			return m, err
+		}
+		fromRate = rate
+	}
+	toRate := 1.0
+	if to != "USD" {
+		rate, err := rates.USDRate(to)
+		if err != nil {
+			return m, err
+		}
+		toRate = rate
+	}
+
+	converted := m.MulRate(toRate / fromRate)
+	converted.Currency = to
+	return converted, nil
+}
+
+// addUSD adds m to a USD total, converting it through rates first. An amount without a rate is left out and logged.
+func addUSD(total, m types.Money, rates RateProvider) types.Money {
+	if m.IsZero() {
+		return total
+	}
+	converted, err := ConvertMoney(m, "USD", rates)
+	if err != nil {
+		slog.Warn("Amount left out of a total", "amount", formatAmount(m), "error", err)
+		return total
+	}
+	return total.Add(converted)
+}
+
+// currencySymbols are used in messages, other currencies are shown by code
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"UAH": "₴",
+	"GBP": "£",
+	"PLN": "zł",
+	"CNY": "¥",
+}
+
+// formatAmount renders "25.00 $" using the money's own currency
+func formatAmount(m types.Money) string {
+	code := m.CurrencyCode()
+	if symbol, ok := currencySymbols[code]; ok {
+		return m.String() + " " + symbol
+	}
+	return m.String() + " " + code
+}
+
+// displayAmount renders an (unsigned) amount in the account's display currency:
+// "25.00 $ (23.10 €)" by default, "23.10 €" with display_mode "instead".
+// Falls back to the original currency if no rate is available.
+func displayAmount(m types.Money, cfg types.AccountConfig) string {
+	original := formatAmount(m)
+	if cfg.DisplayCurrency == "" || strings.EqualFold(cfg.DisplayCurrency, m.CurrencyCode()) {
+		return original
+	}
+
+	converted, err := ConvertMoney(m, cfg.DisplayCurrency, RateProviderFor(cfg))
+	if err != nil {
+		slog.Warn("FX conversion failed", "account", cfg.Label, "error", err)
+		return original
+	}
+
+	if cfg.DisplayMode == "instead" {
+		return formatAmount(converted)
+	}
+	return fmt.Sprintf("%s (%s)", original, formatAmount(converted))
+}
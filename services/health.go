@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Health tracks the polling state of every running account
+type Health struct {
+	mu       sync.Mutex
+	accounts map[string]*accountHealth
+}
+
+type accountHealth struct {
+	state      types.AccountHealth
+	staleAfter time.Duration
+	alerted    bool // Stale alert sent, waiting for recovery
+}
+
+func NewHealth() *Health {
+	return &Health{accounts: make(map[string]*accountHealth)}
+}
+
+// Start registers an account, its staleness is counted from now
+func (h *Health) Start(cfg types.AccountConfig) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.accounts[cfg.Label] = &accountHealth{
+		state:      types.AccountHealth{Label: cfg.Label, StartedAt: time.Now()},
+		staleAfter: time.Duration(cfg.StaleAfter),
+	}
+}
+
+// Remove forgets a stopped account
+func (h *Health) Remove(label string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.accounts, label)
+}
+
+// Report records the result of one poll (err nil = success)
+func (h *Health) Report(cfg types.AccountConfig, err error, degraded bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	account, ok := h.accounts[cfg.Label]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	account.state.LastPoll = now
+	account.state.Degraded = degraded
+	account.staleAfter = time.Duration(cfg.StaleAfter)
+
+	if err != nil {
+		account.state.ConsecutiveFailures++
+		account.state.LastError = Redact(err.Error())
+		return
+	}
+	account.state.LastSuccess = now
+	account.state.ConsecutiveFailures = 0
+	account.state.LastError = ""
+}
+
+// Snapshot returns every account's state sorted by label, with Stale computed for now
+func (h *Health) Snapshot() []types.AccountHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	states := make([]types.AccountHealth, 0, len(h.accounts))
+	for _, account := range h.accounts {
+		state := account.state
+		state.Stale = account.stale(now)
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Label < states[j].Label })
+	return states
+}
+
+// stale is true when no poll succeeded for staleAfter (counted from start before the first success)
+func (a *accountHealth) stale(now time.Time) bool {
+	if a.staleAfter <= 0 {
+		return false
+	}
+	since := a.state.LastSuccess
+	if since.IsZero() {
+		since = a.state.StartedAt
+	}
+	return now.Sub(since) > a.staleAfter
+}
+
+// HealthHandler serves /healthz: the process is up, with the state of every account (always 200)
+func (h *Health) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, http.StatusOK, "ok", h.Snapshot())
+	})
+}
+
+// ReadyHandler serves /readyz: 200 once every account has polled successfully and none is stale, 503 otherwise
+func (h *Health) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		states := h.Snapshot()
+		for _, state := range states {
+			if state.LastSuccess.IsZero() || state.Stale {
+				writeHealth(w, http.StatusServiceUnavailable, "not ready", states)
+				return
+			}
+		}
+		writeHealth(w, http.StatusOK, "ready", states)
+	})
+}
+
+func writeHealth(w http.ResponseWriter, code int, status string, states []types.AccountHealth) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(struct {
+		Status   string                `json:"status"`
+		Accounts []types.AccountHealth `json:"accounts"`
+	}{status, states})
+}
+
+// StartHealthMonitor sends an alert when an account becomes stale and a notice when it recovers.
+// Alerts go to each account's alert chat (owner notifier or its own chat).
+func StartHealthMonitor(ctx context.Context, accounts func() []types.AccountConfig, shared *Shared, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for sleepContext(ctx, 30*time.Second) {
+		configs := make(map[string]types.AccountConfig)
+		for _, cfg := range accounts() {
+			configs[cfg.Label] = cfg
+		}
+
+		for _, alert := range shared.Health.transitions(time.Now(), configs) {
+			cfg := configs[alert.label]
+			if alert.recovered {
+				slog.Info("Account recovered", "account", cfg.Label)
+			} else {
+				slog.Warn("Account is stale", "account", cfg.Label)
+			}
+			sendAlert(shared, cfg, alert.message)
+		}
+	}
+}
+
+// sendAlert queues a plain-text message to the account's alert chat (nothing in dry-run or without a bot)
+func sendAlert(shared *Shared, cfg types.AccountConfig, message string) {
+	if cfg.DryRun || cfg.AlertToken == "" || cfg.AlertChatID == "" {
+		return
+	}
+	bot := shared.Bots.Get(cfg.AlertToken)
+	if bot == nil {
+		return
+	}
+	shared.Outbox.Send(cfg.Label, bot, tgbotapi.NewMessageToChannel(cfg.AlertChatID, message))
+}
+
+type healthAlert struct {
+	label     string
+	recovered bool
+	message   string
+}
+
+// transitions flips the alerted flag of accounts that became stale or recovered and describes them
+func (h *Health) transitions(now time.Time, configs map[string]types.AccountConfig) []healthAlert {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var alerts []healthAlert
+	for label, account := range h.accounts {
+		if _, running := configs[label]; !running {
+			continue
+		}
+
+		stale := account.stale(now)
+		switch {
+		case stale && !account.alerted:
+			account.alerted = true
+			since := account.state.LastSuccess
+			if since.IsZero() {
+				since = account.state.StartedAt
+			}
+			message := fmt.Sprintf("⚠️ %s: no successful poll for %s (%d failures in a row)",
+				label, formatAge(int64(now.Sub(since).Seconds())), account.state.ConsecutiveFailures)
+			if account.state.LastError != "" {
+				message += "\nLast error: " + account.state.LastError
+			}
+			alerts = append(alerts, healthAlert{label: label, message: message})
+		case !stale && account.alerted:
+			account.alerted = false
+			alerts = append(alerts, healthAlert{label: label, recovered: true, message: fmt.Sprintf("✅ %s: polling again", label)})
+		}
+	}
+	return alerts
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+)
+
+// HoldingStats groups completed sells by key and computes time-to-sell statistics.
+// Only sells with a known buy price and acquisition time are counted, reverted sells are ignored.
+// Profits are summed in USD (converted through rates).
+func HoldingStats(entries []types.LedgerEntry, rates RateProvider, key func(types.LedgerEntry) string) []types.HoldingStat {
+	days := make(map[string][]float64)
+	profit := make(map[string]types.Money)
+
+	for _, entry := range entries {
+		if entry.Action != "Sell" || entry.Status == "reverted" {
+			continue
+		}
+		if entry.BuyPrice.Cents <= 0 || entry.AcquiredAt <= 0 || entry.Time < entry.AcquiredAt {
+			continue
+		}
+		k := key(entry)
+		days[k] = append(days[k], float64(entry.Time-entry.AcquiredAt)/86400)
+		profit[k] = addUSD(profit[k], entry.Profit, rates)
+	}
+
+	var stats []types.HoldingStat
+	for k, held := range days {
+		sort.Float64s(held)
+
+		total := 0.0
+		for _, d := range held {
+			total += d
+		}
+
+		stat := types.HoldingStat{
+			Key:        k,
+			Sells:      len(held),
+			MedianDays: median(held),
+			Profit:     profit[k],
+		}
+		if total > 0 {
+			stat.ProfitPerDay = profit[k].MulRate(1 / total)
+		}
+		stats = append(stats, stat)
+	}
+
+	// Most traded first
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Sells != stats[j].Sells {
+			return stats[i].Sells > stats[j].Sells
+		}
+		return stats[i].Key < stats[j].Key
+	})
+	return stats
+}
+
+// ItemCategory reduces "StatTrak™ AK-47 | Redline (Field-Tested)" to "AK-47"
+func ItemCategory(title string) string {
+	name := strings.TrimPrefix(title, "★ ")
+	name = strings.TrimPrefix(name, "StatTrak™ ")
+	name = strings.TrimPrefix(name, "Souvenir ")
+
+	if i := strings.Index(name, " | "); i >= 0 {
+		name = name[:i]
+	} else if i := strings.Index(name, " ("); i >= 0 {
+		name = name[:i]
+	}
+
+	if strings.HasPrefix(title, "★") {
+		return "★ " + name
+	}
+	return name
+}
+
+// FormatHoldingReport renders per-account, per-category and per-item holding stats
+func FormatHoldingReport(cfg types.AccountConfig, entries []types.LedgerEntry) string {
+	var sb strings.Builder
+	label, rates := cfg.Label, RateProviderFor(cfg)
+
+	account := HoldingStats(entries, rates, func(types.LedgerEntry) string { return label })
+	if len(account) == 0 {
+		return fmt.Sprintf("Holding: %s\n\nNo sells with known buy date yet", label)
+	}
+
+	sb.WriteString(fmt.Sprintf("Holding: %s\n\n", label))
+	sb.WriteString(formatHoldingLine(account[0]))
+
+	sb.WriteString("\n\nBy category:\n")
+	for _, stat := range HoldingStats(entries, rates, func(e types.LedgerEntry) string { return ItemCategory(e.Title) }) {
+		sb.WriteString(formatHoldingLine(stat) + "\n")
+	}
+
+	sb.WriteString("\nBy item:\n")
+	for i, stat := range HoldingStats(entries, rates, func(e types.LedgerEntry) string { return e.Title }) {
+		if i == 20 {
+			sb.WriteString("...\n")
+			break
+		}
+		sb.WriteString(formatHoldingLine(stat) + "\n")
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func formatHoldingLine(stat types.HoldingStat) string {
+	return fmt.Sprintf("%s: %d sells, median %.1f days, profit %s (%s/day)",
+		stat.Key, stat.Sells, stat.MedianDays, signed(stat.Profit), signed(stat.ProfitPerDay))
+}
+
+// median expects sorted values
+func median(values []float64) float64 {
+	n := len(values)
+	if n == 0 {
+		return 0
+	}
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ServeHTTP runs the HTTP server (metrics etc.) until ctx is cancelled, then shuts it down gracefully
+func ServeHTTP(ctx context.Context, addr string, handler http.Handler, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("HTTP server listening", "addr", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("HTTP server failed", "addr", addr, "error", err)
+	}
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Telegram refuses photo captions longer than this
+const telegramCaptionLimit = 1024
+
+// How long a looked up item image (or its absence) is reused
+const itemImageTTL = 24 * time.Hour
+
+// Item images by title, for history entries without one
+var itemImages = struct {
+	sync.Mutex
+	byTitle map[string]cachedImage
+}{byTitle: make(map[string]cachedImage)}
+
+type cachedImage struct {
+	url       string
+	fetchedAt time.Time
+}
+
+// deliverItemMessage posts the message with the item's buttons, as the caption of the item's photo when
+// send_images is on. It falls back to text without an image, when the caption is too long or when Telegram
+// can't fetch the image.
+func deliverItemMessage(ctx context.Context, outbox *Outbox, bot *tgbotapi.BotAPI, cfg types.AccountConfig, tx types.Transaction, message string) {
+	keyboard := transactionButtons(tx, cfg)
+	if !cfg.SendImages || tx.Subject == "" || utf8.RuneCountInString(message) > telegramCaptionLimit {
+		deliverWithButtons(outbox, bot, cfg, message, keyboard)
+		return
+	}
+
+	// Nothing is sent in dry-run or without a bot, so no image is looked up for it
+	if cfg.DryRun || bot == nil {
+		if cfg.DryRun && tx.Details.Image != "" {
+			fmt.Printf("[%s] Photo: %s\n", cfg.Label, tx.Details.Image)
+		}
+		deliverWithButtons(outbox, bot, cfg, message, keyboard)
+		return
+	}
+
+	image := transactionImage(ctx, tx)
+	if image == "" {
+		deliverWithButtons(outbox, bot, cfg, message, keyboard)
+		return
+	}
+
+	photo := tgbotapi.NewPhotoToChannel(cfg.TelegramChatID, tgbotapi.FileURL(image))
+	photo.Caption = message
+	photo.ParseMode = "Markdown"
+
+	text := tgbotapi.NewMessageToChannel(cfg.TelegramChatID, message)
+	text.ParseMode = "Markdown"
+
+	if keyboard != nil {
+		photo.ReplyMarkup = *keyboard
+		text.ReplyMarkup = *keyboard
+	}
+
+	outbox.SendOrFallback(cfg.Label, bot, photo, text)
+}
+
+// transactionImage is the image from the history entry, else the one of the cheapest market offer of the title
+func transactionImage(ctx context.Context, tx types.Transaction) string {
+	if tx.Details.Image != "" {
+		return tx.Details.Image
+	}
+
+	itemImages.Lock()
+	cached, ok := itemImages.byTitle[tx.Subject]
+	itemImages.Unlock()
+	if ok && time.Since(cached.fetchedAt) < itemImageTTL {
+		return cached.url
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	item, err := fetchCheapestMarketItem(ctx, tx.Subject)
+	if ctx.Err() != nil {
+		return ""
+	}
+	if err != nil {
+		Logger(ctx).Warn("Item image lookup failed", "item", tx.Subject, "error", err)
+		return ""
+	}
+
+	url := ""
+	if item != nil {
+		url = item.Image
+	}
+	itemImages.Lock()
+	itemImages.byTitle[tx.Subject] = cachedImage{url: url, fetchedAt: time.Now()}
+	itemImages.Unlock()
+	return url
+}
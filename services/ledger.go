@@ -0,0 +1,194 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+)
+
+// Ledger is an append-only JSON-lines log of processed transactions.
+// A transaction can be written several times (trade_protected -> success/reverted), the latest line wins.
+type Ledger struct {
+	mu      sync.RWMutex
+	file    *os.File
+	entries []types.LedgerEntry
+	index   map[string]int // Account/TxID -> position in entries
+}
+
+// OpenLedger loads the existing ledger (if any) and opens it for appending
+func OpenLedger(path string) (*Ledger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger := &Ledger{file: file, index: make(map[string]int)}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var entry types.LedgerEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			slog.Warn("Ledger line skipped", "line", line, "error", err)
+			continue
+		}
+		ledger.apply(entry)
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return ledger, nil
+}
+
+// Record stores the entry in memory and appends it to the file.
+// A released trade keeps the release time of its trade_protected line.
+func (l *Ledger) Record(entry types.LedgerEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if pos, ok := l.index[entry.Account+"/"+entry.TxID]; ok && entry.ReleaseAt == 0 {
+		entry.ReleaseAt = l.entries[pos].ReleaseAt
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.apply(entry)
+	_, err = l.file.Write(append(line, '\n'))
+	return err
+}
+
+// Entries returns the latest state of every transaction, oldest first. Empty account means all accounts.
+func (l *Ledger) Entries(account string) []types.LedgerEntry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var result []types.LedgerEntry
+	for _, entry := range l.entries {
+		if account == "" || entry.Account == account {
+			result = append(result, entry)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Time < result[j].Time })
+	return result
+}
+
+// Lookup returns the latest stored state of a transaction
+func (l *Ledger) Lookup(account, txID string) (types.LedgerEntry, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	pos, ok := l.index[account+"/"+txID]
+	if !ok {
+		return types.LedgerEntry{}, false
+	}
+	return l.entries[pos], true
+}
+
+// Close flushes and closes the ledger file, closing twice is a no-op
+func (l *Ledger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// apply inserts or replaces an entry, caller must hold the lock (or be the loader)
+func (l *Ledger) apply(entry types.LedgerEntry) {
+	key := entry.Account + "/" + entry.TxID
+	if pos, ok := l.index[key]; ok {
+		l.entries[pos] = entry
+		return
+	}
+	l.index[key] = len(l.entries)
+	l.entries = append(l.entries, entry)
+}
+
+// NewLedgerEntry converts a DMarket transaction, looking up the cost basis for sells.
+// A buy paid in another currency is converted with rates, without a rate the profit stays unknown.
+// Must be called before the cost map is updated with this transaction.
+func NewLedgerEntry(account string, tx types.Transaction, costs types.CostMap, mu *sync.RWMutex, rates RateProvider) types.LedgerEntry {
+	entry := types.LedgerEntry{
+		Account:   account,
+		TxID:      tx.ID,
+		Type:      tx.Type,
+		Action:    tx.Action,
+		Status:    tx.Status,
+		ItemID:    tx.Details.ItemID,
+		Title:     tx.Subject,
+		Float:     tx.Details.Extra.FloatValue,
+		PaintSeed: tx.Details.Extra.PaintSeed,
+		Time:      tx.CreatedAt,
+		UpdatedAt: tx.UpdatedAt,
+	}
+
+	// Trade protected until the settlement time (or the usual 7 days when DMarket doesn't say)
+	if tx.Status == "trade_protected" {
+		entry.ReleaseAt = tx.Details.SettlementTime
+		if entry.ReleaseAt == 0 {
+			entry.ReleaseAt = tx.CreatedAt + int64(tradeProtection/time.Second)
+		}
+	}
+
+	if len(tx.Changes) > 0 {
+		entry.Amount = tx.Changes[0].Money
+	}
+	entry.Balance = tx.Balance
+
+	// Deposits, withdrawals, refunds and item transfers are capital, not P&L
+	entry.Flow = ActivityFlow(tx)
+	entry.Capital = capitalChange(tx)
+
+	if tx.Action == "Sell" && tx.Details.ItemID != "" {
+		mu.RLock()
+		cost, found := costs[tx.Details.ItemID]
+		mu.RUnlock()
+
+		buyPrice, err := ConvertMoney(cost.Price, entry.Amount.CurrencyCode(), rates)
+		if found && cost.Price.Cents > 0 && err == nil {
+			entry.BuyPrice = buyPrice
+			entry.Profit = entry.Amount.Sub(buyPrice)
+			entry.AcquiredAt = cost.AcquiredAt
+		}
+	}
+
+	return entry
+}
+
+// sameLedgerEntry compares the stored entry a with b by value (PaintSeed is a pointer).
+// b without a release time matches, Record would keep the stored one.
+func sameLedgerEntry(a, b types.LedgerEntry) bool {
+	if b.ReleaseAt == 0 {
+		b.ReleaseAt = a.ReleaseAt
+	}
+	if (a.PaintSeed == nil) != (b.PaintSeed == nil) {
+		return false
+	}
+	if a.PaintSeed != nil && *a.PaintSeed != *b.PaintSeed {
+		return false
+	}
+	a.PaintSeed, b.PaintSeed = nil, nil
+	return a == b
+}
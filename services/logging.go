@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+)
+
+// SetupLogging installs the default logger on stderr.
+// level: debug, info, warn, error. format: text or json.
+func SetupLogging(level, format string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q (debug, info, warn, error)", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid log format %q (text, json)", format)
+	}
+
+	slog.SetDefault(slog.New(&redactingHandler{next: handler}))
+	return nil
+}
+
+// Known secrets (from the config) plus patterns that look like keys or tokens
+var (
+	redactMu      sync.RWMutex
+	redactSecrets []string
+
+	secretPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`\d{5,}:[A-Za-z0-9_-]{30,}`), // Telegram bot token (also inside api.telegram.org/bot<token>/ URLs)
+		regexp.MustCompile(`\b[0-9a-fA-F]{64,}\b`),      // DMarket public/secret keys
+	}
+)
+
+// RedactSecrets registers the keys and tokens of the accounts and the API tokens, they are masked in every log line
+func RedactSecrets(config types.Config) {
+	var secrets []string
+	for _, cfg := range config.Accounts {
+		for _, secret := range []string{cfg.DMarketKey, cfg.CSFloatKey, cfg.TelegramToken, cfg.AlertToken} {
+			if len(secret) >= 8 {
+				secrets = append(secrets, secret)
+			}
+		}
+	}
+	for _, token := range config.APITokens {
+		secrets = append(secrets, token)
+	}
+
+	redactMu.Lock()
+	redactSecrets = secrets
+	redactMu.Unlock()
+}
+
+// Redact masks every known secret and anything shaped like a key or token
+func Redact(text string) string {
+	redactMu.RLock()
+	for _, secret := range redactSecrets {
+		text = strings.ReplaceAll(text, secret, "[REDACTED]")
+	}
+	redactMu.RUnlock()
+
+	for _, pattern := range secretPatterns {
+		text = pattern.ReplaceAllString(text, "[REDACTED]")
+	}
+	return text
+}
+
+// redactingHandler runs Redact over the message and every string-ish attribute
+type redactingHandler struct {
+	next slog.Handler
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	clean := slog.NewRecord(record.Time, record.Level, Redact(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		clean.AddAttrs(redactAttr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, clean)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clean := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		clean[i] = redactAttr(attr)
+	}
+	return &redactingHandler{next: h.next.WithAttrs(clean)}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{next: h.next.WithGroup(name)}
+}
+
+func redactAttr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, Redact(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		clean := make([]any, len(group))
+		for i, a := range group {
+			clean[i] = redactAttr(a)
+		}
+		return slog.Group(attr.Key, clean...)
+	case slog.KindAny:
+		// Errors and other values are rendered to text first
+		if err, ok := value.Any().(error); ok {
+			return slog.String(attr.Key, Redact(err.Error()))
+		}
+		return slog.String(attr.Key, Redact(fmt.Sprint(value.Any())))
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
+
+// Workers carry their account logger in the context, so API calls are tagged with the account
+type loggerKey struct{}
+
+// WithLogger returns a context whose API calls log through logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Logger returns the context's logger (the default logger if none)
+func Logger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+type accountKey struct{}
+
+// WithAccount tags the context's logger with the account label (once, nested calls keep it)
+func WithAccount(ctx context.Context, label string) context.Context {
+	if current, _ := ctx.Value(accountKey{}).(string); current == label {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, accountKey{}, label)
+	return WithLogger(ctx, slog.Default().With("account", label))
+}
+
+// accountFrom returns the account label set by WithAccount ("" if none)
+func accountFrom(ctx context.Context) string {
+	label, _ := ctx.Value(accountKey{}).(string)
+	return label
+}
+
+type secretPathKey struct{}
+
+// withSecretPath marks the requests of ctx as having a secret in their URL (webhook tokens):
+// metrics use label as the endpoint, logs and errors show the host only
+func withSecretPath(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, secretPathKey{}, label)
+}
+
+// doRequest sends an API request, logs endpoint, status and latency
+// (debug on success, warn otherwise) and records it in the metrics
+func doRequest(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := client.Do(req)
+	latency := time.Since(start)
+
+	endpoint, logged := req.URL.Path, req.URL.Host+req.URL.Path
+	if label, secret := ctx.Value(secretPathKey{}).(string); secret {
+		endpoint, logged = label, req.URL.Host+"/[REDACTED]"
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = req.URL.Scheme + "://" + logged
+		}
+	}
+
+	status := 0
+	if err == nil {
+		status = resp.StatusCode
+	}
+	if ctx.Err() == nil {
+		observeRequest(ctx, req.URL.Host, endpoint, status, latency)
+	}
+
+	log := Logger(ctx).With("method", req.Method, "endpoint", logged, "latency_ms", latency.Milliseconds())
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Warn("Request failed", "error", err)
+		}
+		return nil, err
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		log.Debug("Request", "status", resp.StatusCode)
+	} else {
+		log.Warn("Request returned an error status", "status", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// errorBody reads a failed response body, shortened for logs and errors
+func errorBody(r io.Reader) string {
+	body, _ := io.ReadAll(io.LimitReader(r, 512))
+	return strings.TrimSpace(string(body))
+}
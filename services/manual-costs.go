@@ -0,0 +1,94 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+)
+
+// ManualCosts remembers buy prices set by hand ("Mark cost" button or /cost),
+// they are applied on top of the cost basis loaded from the buy histories on every start
+type ManualCosts struct {
+	mu    sync.Mutex
+	path  string
+	costs map[string]manualCost // By item ID
+}
+
+type manualCost struct {
+	Price    types.Money `json:"price"`
+	MarkedAt int64       `json:"markedAt"`
+}
+
+// OpenManualCosts loads the file (a missing file is empty)
+func OpenManualCosts(path string) (*ManualCosts, error) {
+	m := &ManualCosts{path: path, costs: make(map[string]manualCost)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &m.costs); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Apply puts the manual prices into the cost map. An item bought again after it was marked keeps its new price.
+func (m *ManualCosts) Apply(costs types.CostMap, mu *sync.RWMutex) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mu.Lock()
+	defer mu.Unlock()
+
+	for itemID, manual := range m.costs {
+		existing := costs[itemID]
+		if existing.AcquiredAt > manual.MarkedAt {
+			continue
+		}
+		costs[itemID] = types.CostEntry{Price: manual.Price, AcquiredAt: existing.AcquiredAt, Manual: true, MarkedAt: manual.MarkedAt}
+	}
+}
+
+// Mark sets an item's buy price in the cost map and saves it
+func (m *ManualCosts) Mark(itemID string, price types.Money, costs types.CostMap, mu *sync.RWMutex) error {
+	markedAt := time.Now().Unix()
+
+	mu.Lock()
+	existing := costs[itemID]
+	costs[itemID] = types.CostEntry{Price: price, AcquiredAt: existing.AcquiredAt, Manual: true, MarkedAt: markedAt}
+	mu.Unlock()
+
+	m.mu.Lock()
+	m.costs[itemID] = manualCost{Price: price, MarkedAt: markedAt}
+	m.mu.Unlock()
+
+	return m.save()
+}
+
+// save writes the file atomically (temp file + rename)
+func (m *ManualCosts) save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, err := json.MarshalIndent(m.costs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.path), 0o755); err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
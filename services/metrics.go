@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus metrics, served on /metrics by "run" when http_addr is set
+var (
+	apiRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dmtracker_api_requests_total",
+		Help: "API calls (DMarket, CSFloat, FX) by endpoint and HTTP status (\"error\" for network errors).",
+	}, []string{"account", "host", "endpoint", "status"})
+
+	apiRateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dmtracker_api_rate_limited_total",
+		Help: "API calls answered with 429 Too Many Requests.",
+	}, []string{"account", "host", "endpoint"})
+
+	apiLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dmtracker_api_request_duration_seconds",
+		Help:    "API call latency.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"account", "host", "endpoint"})
+
+	pollDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dmtracker_poll_duration_seconds",
+		Help:    "Duration of one tracker poll (history, balance, processing).",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"account"})
+
+	polls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dmtracker_polls_total",
+		Help: "Tracker polls by result (ok, error).",
+	}, []string{"account", "result"})
+
+	transactionsSeen = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dmtracker_transactions_seen_total",
+		Help: "New or updated transactions returned by DMarket.",
+	}, []string{"account", "type", "status"})
+
+	transactionsPosted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dmtracker_transactions_posted_total",
+		Help: "Transactions turned into a message.",
+	}, []string{"account", "type", "status"})
+
+	transactionsSkipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dmtracker_transactions_skipped_total",
+		Help: "Transactions not posted (ignore_released).",
+	}, []string{"account", "type", "status"})
+
+	ruleMatches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dmtracker_rule_matches_total",
+		Help: "Transactions matching an alert rule.",
+	}, []string{"account", "rule"})
+
+	webhookFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dmtracker_webhook_failures_total",
+		Help: "Rule webhooks that failed after retries or got a non-2xx answer.",
+	}, []string{"account", "rule"})
+
+	telegramFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dmtracker_telegram_send_failures_total",
+		Help: "Transaction messages Telegram refused or that failed to send.",
+	}, []string{"account"})
+
+	csfloatMatches = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dmtracker_csfloat_matches",
+		Help: "DMarket inventory items priced from CSFloat buys in the last sync.",
+	}, []string{"account"})
+
+	balanceAvailable = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dmtracker_balance_available_usd",
+		Help: "Available balance at the last balance snapshot.",
+	}, []string{"account"})
+
+	balanceTradeProtected = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dmtracker_balance_trade_protected_usd",
+		Help: "Trade-protected balance at the last balance snapshot.",
+	}, []string{"account"})
+)
+
+// RegisterCostBasisMetric exposes the size of the shared cost map
+func RegisterCostBasisMetric(costs types.CostMap, mu *sync.RWMutex) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "dmtracker_cost_basis_items",
+		Help: "Items with a known buy price (all accounts share one map).",
+	}, func() float64 {
+		mu.RLock()
+		defer mu.RUnlock()
+		return float64(len(costs))
+	})
+}
+
+// observeRequest records one API call, status 0 means a network error
+func observeRequest(ctx context.Context, host, endpoint string, status int, latency time.Duration) {
+	account := accountFrom(ctx)
+
+	code := "error"
+	if status != 0 {
+		code = strconv.Itoa(status)
+	}
+	apiRequests.WithLabelValues(account, host, endpoint, code).Inc()
+	apiLatency.WithLabelValues(account, host, endpoint).Observe(latency.Seconds())
+	if status == 429 {
+		apiRateLimited.WithLabelValues(account, host, endpoint).Inc()
+	}
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Outbox sends Telegram messages one by one from a single goroutine,
+// so shutdown can wait for the queued ones instead of dropping them
+type Outbox struct {
+	mu     sync.Mutex
+	closed bool
+	queue  chan outboxMessage
+	done   chan struct{}
+}
+
+type outboxMessage struct {
+	label string
+	bot   *tgbotapi.BotAPI
+	msg   tgbotapi.Chattable
+	then  func(sent tgbotapi.Message) // Optional, called after a successful send
+
+	fallback tgbotapi.Chattable // Optional, sent instead when msg fails (e.g. a photo Telegram can't fetch)
+}
+
+func NewOutbox(size int) *Outbox {
+	o := &Outbox{
+		queue: make(chan outboxMessage, size),
+		done:  make(chan struct{}),
+	}
+	go o.run()
+	return o
+}
+
+// Send queues a message (blocks while the queue is full). Messages sent after Flush are dropped.
+func (o *Outbox) Send(label string, bot *tgbotapi.BotAPI, msg tgbotapi.Chattable) {
+	o.SendThen(label, bot, msg, nil)
+}
+
+// SendThen queues a message like Send and calls then with the sent message (from the outbox goroutine)
+func (o *Outbox) SendThen(label string, bot *tgbotapi.BotAPI, msg tgbotapi.Chattable, then func(sent tgbotapi.Message)) {
+	o.enqueue(outboxMessage{label: label, bot: bot, msg: msg, then: then})
+}
+
+// SendOrFallback queues msg like Send, fallback is sent in its place if Telegram refuses msg
+func (o *Outbox) SendOrFallback(label string, bot *tgbotapi.BotAPI, msg, fallback tgbotapi.Chattable) {
+	o.enqueue(outboxMessage{label: label, bot: bot, msg: msg, fallback: fallback})
+}
+
+func (o *Outbox) enqueue(m outboxMessage) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		slog.Warn("Outbox closed, message dropped", "account", m.label)
+		return
+	}
+	o.queue <- m
+}
+
+// Flush stops accepting messages and waits up to timeout for the queue to drain
+func (o *Outbox) Flush(timeout time.Duration) error {
+	o.mu.Lock()
+	if !o.closed {
+		o.closed = true
+		close(o.queue)
+	}
+	o.mu.Unlock()
+
+	select {
+	case <-o.done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("%d message(s) still queued after %s", len(o.queue), timeout)
+	}
+}
+
+func (o *Outbox) run() {
+	defer close(o.done)
+
+	for m := range o.queue {
+		sent, err := m.bot.Send(m.msg)
+		if err != nil && m.fallback != nil {
+			slog.Warn("Telegram send failed, sending fallback", "account", m.label, "error", err)
+			sent, err = m.bot.Send(m.fallback)
+		}
+		if err != nil {
+			slog.Error("Telegram send failed", "account", m.label, "error", err)
+			telegramFailures.WithLabelValues(m.label).Inc()
+			continue
+		}
+		if m.then != nil {
+			m.then(sent)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Request budgets per API key. Every account, poller and report using the same key shares one bucket.
+const (
+	dmarketRequestsPerSecond = 5
+	dmarketBurst             = 5
+	csfloatRequestsPerSecond = 1
+	csfloatBurst             = 2
+	webhookRequestsPerSecond = 5 // Per webhook URL
+	webhookBurst             = 5
+)
+
+// Retry policy for network errors, 429 and 5xx (other statuses are returned as they are)
+const (
+	maxAttempts  = 5
+	retryBase    = time.Second
+	retryMaxWait = 30 * time.Second
+)
+
+// tokenBucket allows rate requests per second with bursts up to burst
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Wait takes one token, sleeping until one is available or ctx is done
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		if !sleepContext(ctx, wait) {
+			return ctx.Err()
+		}
+	}
+}
+
+// One bucket per API key
+var (
+	limitersMu sync.Mutex
+	limiters   = make(map[string]*tokenBucket)
+)
+
+func limiterFor(key string, rate, burst float64) *tokenBucket {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	limiter, ok := limiters[key]
+	if !ok {
+		limiter = newTokenBucket(rate, burst)
+		limiters[key] = limiter
+	}
+	return limiter
+}
+
+var apiClient = &http.Client{Timeout: 30 * time.Second}
+
+// sendWithRetry waits for the limiter, sends the request built by newRequest and retries
+// network errors, 429 and 5xx with exponential backoff and jitter, up to maxAttempts.
+// newRequest is called for every attempt (DMarket signatures are time-based).
+func sendWithRetry(ctx context.Context, limiter *tokenBucket, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	var lastErr error
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			if !sleepContext(ctx, backoff(attempt, lastErr)) {
+				return nil, ctx.Err()
+			}
+		}
+		if err := limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := doRequest(ctx, apiClient, req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+
+		if resp.StatusCode == 429 || resp.StatusCode >= 500 {
+			lastErr = &retryableStatus{status: resp.StatusCode, retryAfter: retryAfter(resp)}
+			resp.Body.Close()
+			continue
+		}
+		return resp, nil
+	}
+
+	return nil, fmt.Errorf("giving up after %d attempts: %v", maxAttempts, lastErr)
+}
+
+// retryableStatus is a 429/5xx answer, retryAfter is the server's Retry-After (0 if none)
+type retryableStatus struct {
+	status     int
+	retryAfter time.Duration
+}
+
+func (e *retryableStatus) Error() string {
+	return fmt.Sprintf("API status %d", e.status)
+}
+
+// backoff doubles from retryBase up to retryMaxWait, with jitter (50-100%).
+// A Retry-After from the server wins if it is longer.
+func backoff(attempt int, lastErr error) time.Duration {
+	wait := min(retryMaxWait, retryBase<<(attempt-1))
+	wait = wait/2 + rand.N(wait/2+1)
+
+	if status, ok := lastErr.(*retryableStatus); ok && status.retryAfter > wait {
+		wait = min(retryMaxWait, status.retryAfter)
+	}
+	return wait
+}
+
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// dmarketRequest sends a signed DMarket request through the key's limiter and the retry policy
+func dmarketRequest(ctx context.Context, secretKey, method, endpoint string) (*http.Response, error) {
+	limiter := limiterFor("dmarket:"+secretKey, dmarketRequestsPerSecond, dmarketBurst)
+
+	return sendWithRetry(ctx, limiter, func() (*http.Request, error) {
+		headers, err := generateHeaders(secretKey, method, endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, method, "https://api.dmarket.com"+endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header = headers
+		return req, nil
+	})
+}
+
+// dmarketPublicRequest sends an unsigned DMarket GET (market prices) through the shared public limiter and the retry policy
+func dmarketPublicRequest(ctx context.Context, endpoint string) (*http.Response, error) {
+	limiter := limiterFor("dmarket:public", dmarketRequestsPerSecond, dmarketBurst)
+
+	return sendWithRetry(ctx, limiter, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, "GET", "https://api.dmarket.com"+endpoint, nil)
+	})
+}
+
+// csfloatRequest sends a CSFloat API GET through the key's limiter and the retry policy
+func csfloatRequest(ctx context.Context, apiKey, url string) (*http.Response, error) {
+	limiter := limiterFor("csfloat:"+apiKey, csfloatRequestsPerSecond, csfloatBurst)
+
+	return sendWithRetry(ctx, limiter, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", apiKey)
+		return req, nil
+	})
+}
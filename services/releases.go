@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Usual trade protection of a DMarket trade, used when the transaction has no settlement time
+const tradeProtection = 7 * 24 * time.Hour
+
+// parseDigestTime reads "08:30" into the offset from midnight (UTC)
+func parseDigestTime(value string) (time.Duration, bool) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, true
+}
+
+// StartReleaseDigest posts the daily "released today" message at the account's release_digest time (UTC)
+// until ctx is cancelled. Trade protection ends in one burst (8:00 GMT), so a single message replaces
+// the success updates that ignore_released drops.
+func StartReleaseDigest(ctx context.Context, handle *AccountHandle, shared *Shared, wg *sync.WaitGroup) {
+	defer wg.Done()
+	ctx = WithAccount(ctx, handle.Config().Label)
+
+	// Started after today's digest time: today's digest was due before, don't repeat it on restart
+	var lastDay string
+	if at, ok := parseDigestTime(handle.Config().ReleaseDigest); ok {
+		now := time.Now().UTC()
+		if now.Sub(now.Truncate(24*time.Hour)) >= at {
+			lastDay = now.Format("2006-01-02")
+		}
+	}
+
+	for sleepContext(ctx, time.Minute) {
+		cfg := handle.Config()
+		at, ok := parseDigestTime(cfg.ReleaseDigest)
+		if !ok {
+			continue
+		}
+
+		now := time.Now().UTC()
+		day := now.Format("2006-01-02")
+		if day == lastDay || now.Sub(now.Truncate(24*time.Hour)) < at {
+			continue
+		}
+		lastDay = day
+
+		postReleaseDigest(ctx, cfg, shared, now)
+	}
+}
+
+// postReleaseDigest refreshes the due trades and posts the digest of the last 24 hours (nothing if empty)
+func postReleaseDigest(ctx context.Context, cfg types.AccountConfig, shared *Shared, now time.Time) {
+	log := Logger(ctx)
+
+	entries, err := refreshReleases(ctx, cfg, shared, now)
+	if err != nil {
+		// Still report what the tracker saw itself
+		log.Warn("Refreshing trade protected sells failed", "error", err)
+	}
+
+	released, reverted := ReleasedSince(entries, now.Add(-24*time.Hour).Unix())
+	if len(released) == 0 && len(reverted) == 0 {
+		log.Debug("No trades released today")
+		return
+	}
+	log.Info("Posting release digest", "released", len(released), "reverted", len(reverted))
+
+	var bot *tgbotapi.BotAPI
+	if !cfg.DryRun {
+		bot = shared.Bots.Get(cfg.TelegramToken)
+	}
+	deliverMessage(shared.Outbox, bot, cfg, FormatReleaseDigest(cfg, released, reverted, PendingReleases(entries)))
+}
+
+// refreshReleases re-reads the history back to the oldest trade_protected sell past its release time.
+// The tracker only sees the newest history_limit transactions, so week-old sells are usually out of its reach.
+// Returns the account's ledger entries with the updates applied (also in dry-run, where nothing is written).
+func refreshReleases(ctx context.Context, cfg types.AccountConfig, shared *Shared, now time.Time) ([]types.LedgerEntry, error) {
+	entries := shared.Ledger.Entries(cfg.Label)
+
+	due := make(map[string]int) // TxID -> position in entries
+	since := int64(0)
+	for i, entry := range entries {
+		if entry.Action == "Sell" && entry.Status == "trade_protected" && entry.ReleaseAt <= now.Unix() {
+			due[entry.TxID] = i
+			if since == 0 || entry.Time < since {
+				since = entry.Time
+			}
+		}
+	}
+	if len(due) == 0 {
+		return entries, nil
+	}
+
+	history, err := FetchFullHistory(ctx, cfg.DMarketKey, since)
+	if err != nil {
+		return entries, err
+	}
+
+	for _, tx := range history {
+		pos, ok := due[tx.ID]
+		if !ok || tx.Status == entries[pos].Status {
+			continue
+		}
+
+		entry := NewLedgerEntry(cfg.Label, tx, shared.Costs, shared.CostMu, RateProviderFor(cfg))
+		entry.ReleaseAt = entries[pos].ReleaseAt
+		entries[pos] = entry
+
+		shared.Balances.Expect(cfg.Label, tx, false, RateProviderFor(cfg))
+		if !cfg.DryRun {
+			if err := shared.Ledger.Record(entry); err != nil {
+				Logger(ctx).Error("Ledger write failed", "tx", tx.ID, "error", err)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// ReleasedSince returns the trade protected sells that settled (success) or were reverted at or after since
+func ReleasedSince(entries []types.LedgerEntry, since int64) (released, reverted []types.LedgerEntry) {
+	for _, entry := range entries {
+		if entry.Action != "Sell" || entry.ReleaseAt == 0 || entry.UpdatedAt < since {
+			continue
+		}
+		switch entry.Status {
+		case "success":
+			released = append(released, entry)
+		case "reverted":
+			reverted = append(reverted, entry)
+		}
+	}
+	return released, reverted
+}
+
+// PendingReleases returns the sells still under trade protection, earliest release first
+func PendingReleases(entries []types.LedgerEntry) []types.LedgerEntry {
+	var pending []types.LedgerEntry
+	for _, entry := range entries {
+		if entry.Action == "Sell" && entry.Status == "trade_protected" {
+			pending = append(pending, entry)
+		}
+	}
+	sort.SliceStable(pending, func(i, j int) bool { return pending[i].ReleaseAt < pending[j].ReleaseAt })
+	return pending
+}
+
+// sumAmounts adds up the money the entries moved, in USD
+func sumAmounts(entries []types.LedgerEntry, rates RateProvider) types.Money {
+	total := types.USD(0)
+	for _, entry := range entries {
+		total = addUSD(total, entry.Amount.Abs(), rates)
+	}
+	return total
+}
+
+// FormatReleaseDigest renders "released today: N trades, X now withdrawable, M reverted" plus what is still pending
+func FormatReleaseDigest(cfg types.AccountConfig, released, reverted, pending []types.LedgerEntry) string {
+	var message strings.Builder
+	message.WriteString(fmt.Sprintf("🔓 `%s` released today: %d trades, %s now withdrawable, %d reverted",
+		cfg.Label, len(released), displayAmount(sumAmounts(released, RateProviderFor(cfg)), cfg), len(reverted)))
+
+	if len(reverted) > 0 {
+		message.WriteString(fmt.Sprintf("\nReverted: %s", displayAmount(sumAmounts(reverted, RateProviderFor(cfg)), cfg)))
+	}
+	if len(pending) > 0 {
+		next := time.Unix(pending[0].ReleaseAt, 0).UTC().Format("2006-01-02")
+		message.WriteString(fmt.Sprintf("\nStill protected: %d trades, %s (next release %s)",
+			len(pending), displayAmount(sumAmounts(pending, RateProviderFor(cfg)), cfg), next))
+	}
+	return message.String()
+}
+
+// FormatPendingReleases lists the trade protected sells grouped by release day (UTC), as plain text
+func FormatPendingReleases(cfg types.AccountConfig, pending []types.LedgerEntry, now time.Time) string {
+	if len(pending) == 0 {
+		return fmt.Sprintf("Pending releases: %s\n\nNothing under trade protection", cfg.Label)
+	}
+	rates := RateProviderFor(cfg)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Pending releases: %s (%d trades, %s)\n", cfg.Label, len(pending), formatAmount(sumAmounts(pending, rates))))
+
+	for start := 0; start < len(pending); {
+		day := time.Unix(pending[start].ReleaseAt, 0).UTC().Format("2006-01-02")
+		end := start
+		for end < len(pending) && time.Unix(pending[end].ReleaseAt, 0).UTC().Format("2006-01-02") == day {
+			end++
+		}
+		group := pending[start:end]
+
+		when := "due, waiting for DMarket"
+		if left := group[0].ReleaseAt - now.Unix(); left > 0 {
+			when = "in " + formatAge(left)
+		}
+		sb.WriteString(fmt.Sprintf("\n%s (%s): %d trades, %s\n", day, when, len(group), formatAmount(sumAmounts(group, rates))))
+		for _, entry := range group {
+			sb.WriteString(fmt.Sprintf("  %s %s\n", entry.Title, formatAmount(entry.Amount.Abs())))
+		}
+		start = end
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
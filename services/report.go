@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+)
+
+// BuildInventoryReport values the account's held items against the cost basis
+func BuildInventoryReport(ctx context.Context, cfg types.AccountConfig, costs types.CostMap, mu *sync.RWMutex) (types.InventoryReport, error) {
+	ctx = WithAccount(ctx, cfg.Label)
+	report := types.InventoryReport{Label: cfg.Label}
+
+	// 1. Fetch held items
+	inventory, err := FetchDMarketInventory(ctx, cfg.DMarketKey)
+	if err != nil {
+		return report, err
+	}
+
+	// 2. Lowest market price per title (one request per unique title, paced by the public limiter)
+	marketPrices := make(map[string]types.Money)
+	for _, item := range inventory {
+		if _, done := marketPrices[item.Title]; done {
+			continue
+		}
+		price, err := FetchLowestMarketPrice(ctx, item.Title)
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+		if err != nil {
+			Logger(ctx).Warn("Fetching market price failed", "item", item.Title, "error", err)
+		}
+		marketPrices[item.Title] = price
+	}
+
+	// 3. Value every item
+	return ValueInventory(cfg.Label, inventory, marketPrices, costs, mu, RateProviderFor(cfg)), nil
+}
+
+// ValueInventory joins held items with the cost basis. marketPrices may be nil (listed items are
+// still valued at their listing price, the others only show the buy price).
+// Buys paid in another currency are converted to USD with rates, without a rate their cost counts as unknown.
+func ValueInventory(label string, inventory []types.DMarketInventoryItem, marketPrices map[string]types.Money, costs types.CostMap, mu *sync.RWMutex, rates RateProvider) types.InventoryReport {
+	report := types.InventoryReport{Label: label}
+
+	mu.RLock()
+	for _, item := range inventory {
+		cost := costs[item.ItemID]
+		if converted, err := ConvertMoney(cost.Price, "USD", rates); err == nil {
+			cost.Price = converted
+		} else {
+			cost.Price = types.Money{}
+		}
+		row := types.InventoryReportItem{
+			ItemID:      item.ItemID,
+			Title:       item.Title,
+			BuyPrice:    cost.Price,
+			MarketPrice: marketPrices[item.Title],
+			HeldSince:   cost.AcquiredAt,
+			Stickers:    item.Extra.Stickers,
+			Keychains:   item.Extra.Keychains,
+			NameTag:     item.Extra.NameTag,
+		}
+		// Fall back to the inventory date when the buy date is unknown
+		if row.HeldSince == 0 {
+			row.HeldSince = item.CreatedAt
+		}
+		if item.InMarket {
+			row.ListedPrice, _ = types.ParseCents(item.Price.USD, "USD")
+		}
+
+		value := row.ListedPrice
+		if value.IsZero() {
+			value = row.MarketPrice
+		}
+
+		switch {
+		case row.BuyPrice.Cents <= 0:
+			report.UnknownCost++
+		case value.IsZero():
+			// No listing or market price, nothing to compare the buy price with
+			report.UnknownValue++
+		default:
+			row.Unrealized = value.Sub(row.BuyPrice)
+			report.TotalCost = report.TotalCost.Add(row.BuyPrice)
+			report.TotalValue = report.TotalValue.Add(value)
+			report.TotalUnrealized = report.TotalUnrealized.Add(row.Unrealized)
+		}
+
+		report.Items = append(report.Items, row)
+	}
+	mu.RUnlock()
+
+	// Biggest winners first
+	sort.SliceStable(report.Items, func(i, j int) bool {
+		return report.Items[i].Unrealized.Cents > report.Items[j].Unrealized.Cents
+	})
+
+	return report
+}
+
+// FetchLowestMarketPrice returns the cheapest current market offer (USD) for a title
+func FetchLowestMarketPrice(ctx context.Context, title string) (types.Money, error) {
+	item, err := fetchCheapestMarketItem(ctx, title)
+	if err != nil || item == nil {
+		return types.Money{}, err
+	}
+	return types.ParseCents(item.Price.USD, "USD")
+}
+
+// fetchCheapestMarketItem returns the cheapest current market offer for a title (nil if there is none)
+func fetchCheapestMarketItem(ctx context.Context, title string) (*types.DMarketInventoryItem, error) {
+	endpoint := fmt.Sprintf("/exchange/v1/market/items?gameId=a8db&limit=1&orderBy=price&orderDir=asc&currency=USD&title=%s", url.QueryEscape(title))
+
+	resp, err := dmarketPublicRequest(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("API status %d", resp.StatusCode)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	var response types.DMarketMarketItemsResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	if len(response.Objects) == 0 {
+		return nil, nil
+	}
+
+	return &response.Objects[0], nil
+}
+
+// FormatInventoryReport renders the report as a plain-text table
+func FormatInventoryReport(report types.InventoryReport) string {
+	var sb strings.Builder
+	now := time.Now().Unix()
+
+	sb.WriteString(fmt.Sprintf("Inventory: %s (%d items)\n\n", report.Label, len(report.Items)))
+
+	for _, item := range report.Items {
+		sb.WriteString(item.Title + "\n")
+
+		// Unrealized needs both a buy price and a current value (listing or market)
+		valued := !item.ListedPrice.IsZero() || !item.MarketPrice.IsZero()
+		buy := "?"
+		profit := "?"
+		if item.BuyPrice.Cents > 0 {
+			buy = formatAmount(item.BuyPrice)
+			if valued {
+				profit = signed(item.Unrealized)
+			}
+		}
+		listed := "-"
+		if !item.ListedPrice.IsZero() {
+			listed = formatAmount(item.ListedPrice)
+		}
+		market := "?"
+		if !item.MarketPrice.IsZero() {
+			market = formatAmount(item.MarketPrice)
+		}
+
+		sb.WriteString(fmt.Sprintf("  Buy: %s | Listed: %s | Market: %s\n", buy, listed, market))
+		age := int64(0)
+		if item.HeldSince > 0 {
+			age = now - item.HeldSince
+		}
+		sb.WriteString(fmt.Sprintf("  Unrealized: %s | Held: %s\n", profit, formatAge(age)))
+		if len(item.Stickers) > 0 {
+			sb.WriteString(fmt.Sprintf("  Stickers: %s\n", formatStickers(item.Stickers)))
+		}
+		if len(item.Keychains) > 0 {
+			sb.WriteString(fmt.Sprintf("  Charm: %s\n", formatStickers(item.Keychains)))
+		}
+		if item.NameTag != "" {
+			sb.WriteString(fmt.Sprintf("  Name tag: \"%s\"\n", item.NameTag))
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("\nCost: %s\nValue: %s\nUnrealized: %s",
+		formatAmount(report.TotalCost), formatAmount(report.TotalValue), signed(report.TotalUnrealized)))
+	if report.UnknownCost > 0 {
+		sb.WriteString(fmt.Sprintf("\n(%d items without buy price excluded)", report.UnknownCost))
+	}
+	if report.UnknownValue > 0 {
+		sb.WriteString(fmt.Sprintf("\n(%d items without market price excluded)", report.UnknownValue))
+	}
+
+	return sb.String()
+}
+
+// formatAge turns seconds into "12d 4h"
+func formatAge(seconds int64) string {
+	if seconds <= 0 {
+		return "?"
+	}
+	days := seconds / 86400
+	hours := (seconds % 86400) / 3600
+	if days == 0 && hours == 0 {
+		return fmt.Sprintf("%dm", seconds/60)
+	}
+	if days == 0 {
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dd %dh", days, hours)
+}
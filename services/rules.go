@@ -0,0 +1,234 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Compiled item patterns of the rules, by pattern (configs are reloaded, patterns rarely change)
+var rulePatterns sync.Map
+
+// How long a webhook (with its retries) may take, also while shutting down
+const webhookTimeout = 30 * time.Second
+
+// RuleMatch is the webhook payload
+type RuleMatch struct {
+	Rule        string            `json:"rule"`
+	Account     string            `json:"account"`
+	Text        string            `json:"text"` // The Telegram message of the transaction (Markdown)
+	Transaction types.LedgerEntry `json:"transaction"`
+}
+
+// validateRule checks a rule from the config: a name, a valid pattern, sane ranges and at least one action
+func validateRule(rule types.Rule) []error {
+	var errs []error
+	if rule.Name == "" {
+		errs = append(errs, errors.New("name is required"))
+	}
+	if rule.Item != "" {
+		if _, err := regexp.Compile(rule.Item); err != nil {
+			errs = append(errs, fmt.Errorf("item: %v", err))
+		}
+	}
+	if rule.MinPrice.Cents > 0 && rule.MaxPrice.Cents > 0 && rule.MinPrice.Cents > rule.MaxPrice.Cents {
+		errs = append(errs, errors.New("min_price is above max_price"))
+	}
+	if rule.MinProfitPercent != nil && rule.MaxProfitPercent != nil && *rule.MinProfitPercent > *rule.MaxProfitPercent {
+		errs = append(errs, errors.New("min_profit_percent is above max_profit_percent"))
+	}
+	if rule.TelegramToken != "" && !telegramTokenPattern.MatchString(rule.TelegramToken) {
+		errs = append(errs, errors.New("telegram_token does not look like \"123456:ABC...\" from @BotFather"))
+	}
+	if rule.TelegramChatID != "" && !telegramChatIDPattern.MatchString(rule.TelegramChatID) {
+		errs = append(errs, errors.New("telegram_chat_id must be numeric (e.g. -100123...) or @channelname"))
+	}
+	if rule.Webhook != "" {
+		if u, err := url.Parse(rule.Webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, errors.New("webhook must be an http(s) URL"))
+		}
+	}
+	if !rule.SendsMessage() && rule.Webhook == "" {
+		errs = append(errs, errors.New("no action (telegram_chat_id, notifier, mention, pin or webhook)"))
+	}
+	return errs
+}
+
+// MatchRule tells if a transaction (and its ledger entry, for the profit) meets every condition of the rule
+func MatchRule(rule types.Rule, tx types.Transaction, entry types.LedgerEntry) bool {
+	if len(rule.Types) > 0 && !slices.Contains(rule.Types, tx.Type) {
+		return false
+	}
+	if len(rule.Statuses) > 0 && !slices.Contains(rule.Statuses, tx.Status) {
+		return false
+	}
+
+	if rule.Item != "" {
+		pattern, ok := rulePatterns.Load(rule.Item)
+		if !ok {
+			compiled, err := regexp.Compile(rule.Item)
+			if err != nil {
+				return false
+			}
+			pattern, _ = rulePatterns.LoadOrStore(rule.Item, compiled)
+		}
+		if !pattern.(*regexp.Regexp).MatchString(tx.Subject) {
+			return false
+		}
+	}
+
+	amount := entry.Amount.Abs()
+	if rule.MinPrice.Cents > 0 && amount.Cents < rule.MinPrice.Cents {
+		return false
+	}
+	if rule.MaxPrice.Cents > 0 && amount.Cents > rule.MaxPrice.Cents {
+		return false
+	}
+
+	if rule.MinProfitPercent != nil || rule.MaxProfitPercent != nil {
+		if entry.BuyPrice.Cents <= 0 {
+			return false
+		}
+		percent := entry.Profit.Percent(entry.BuyPrice)
+		if rule.MinProfitPercent != nil && percent < *rule.MinProfitPercent {
+			return false
+		}
+		if rule.MaxProfitPercent != nil && percent > *rule.MaxProfitPercent {
+			return false
+		}
+	}
+
+	if rule.MaxFloat > 0 && (entry.Float == 0 || entry.Float >= rule.MaxFloat) {
+		return false
+	}
+	if len(rule.PaintSeeds) > 0 && (entry.PaintSeed == nil || !slices.Contains(rule.PaintSeeds, *entry.PaintSeed)) {
+		return false
+	}
+	return true
+}
+
+// ApplyRules runs the actions of every rule the transaction matches. text is the transaction's message.
+// Webhooks are posted in the background, wg waits for them on shutdown.
+func ApplyRules(ctx context.Context, shared *Shared, cfg types.AccountConfig, tx types.Transaction, entry types.LedgerEntry, text string, wg *sync.WaitGroup) {
+	for _, rule := range cfg.Rules {
+		if !MatchRule(rule, tx, entry) {
+			continue
+		}
+		Logger(ctx).Info("Rule matched", "rule", rule.Name, "tx", tx.ID, "item", tx.Subject)
+		ruleMatches.WithLabelValues(cfg.Label, rule.Name).Inc()
+
+		if rule.SendsMessage() {
+			sendRuleMessage(ctx, shared, cfg, rule, text)
+		}
+		if rule.Webhook != "" {
+			match := RuleMatch{Rule: rule.Name, Account: cfg.Label, Text: text, Transaction: entry}
+			if cfg.DryRun {
+				fmt.Printf("[%s] Rule %s webhook: POST %s\n\n", cfg.Label, rule.Name, rule.Webhook)
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				postWebhook(ctx, cfg.Label, rule.Webhook, match)
+			}()
+		}
+	}
+}
+
+// sendRuleMessage posts "🔔 rule @mention" plus the transaction to the rule's chat and pins it if asked
+func sendRuleMessage(ctx context.Context, shared *Shared, cfg types.AccountConfig, rule types.Rule, text string) {
+	log := Logger(ctx).With("rule", rule.Name)
+
+	token, chatID := rule.TelegramToken, rule.TelegramChatID
+	if token == "" {
+		token = cfg.TelegramToken
+	}
+	if chatID == "" {
+		chatID = cfg.TelegramChatID
+	}
+
+	header := fmt.Sprintf("🔔 %s", escapeMarkdown(rule.Name))
+	if rule.Mention != "" {
+		header += " " + escapeMarkdown(rule.Mention)
+	}
+	message := header + "\n\n" + text
+
+	if cfg.DryRun {
+		fmt.Printf("[%s] Rule %s -> %s:\n%s\n\n", cfg.Label, rule.Name, chatID, message)
+		return
+	}
+	bot := shared.Bots.Get(token)
+	if bot == nil {
+		log.Warn("No Telegram bot, rule message not sent", "text", message)
+		return
+	}
+
+	msg := tgbotapi.NewMessageToChannel(chatID, message)
+	msg.ParseMode = "Markdown"
+	if !rule.Pin {
+		shared.Outbox.Send(cfg.Label, bot, msg)
+		return
+	}
+	shared.Outbox.SendThen(cfg.Label, bot, msg, func(sent tgbotapi.Message) {
+		if sent.Chat == nil {
+			return
+		}
+		pin := tgbotapi.PinChatMessageConfig{ChatID: sent.Chat.ID, MessageID: sent.MessageID}
+		if _, err := bot.Request(pin); err != nil {
+			log.Warn("Pinning rule message failed", "error", err)
+		}
+	})
+}
+
+// postWebhook sends the match as JSON through the retry policy. It is not cancelled by shutdown (only
+// bounded by webhookTimeout), failures are logged and counted.
+func postWebhook(ctx context.Context, account, target string, match RuleMatch) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), webhookTimeout)
+	defer cancel()
+	// Discord and Slack webhook URLs carry their token in the path
+	ctx = withSecretPath(ctx, "webhook")
+	log := Logger(ctx).With("rule", match.Rule)
+
+	body, err := json.Marshal(match)
+	if err != nil {
+		log.Error("Webhook payload failed", "error", err)
+		webhookFailures.WithLabelValues(account, match.Rule).Inc()
+		return
+	}
+
+	limiter := limiterFor("webhook:"+target, webhookRequestsPerSecond, webhookBurst)
+	resp, err := sendWithRetry(ctx, limiter, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", target, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		log.Warn("Webhook failed", "error", err)
+		webhookFailures.WithLabelValues(account, match.Rule).Inc()
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		webhookFailures.WithLabelValues(account, match.Rule).Inc()
+	}
+}
+
+// escapeMarkdown escapes the characters Telegram's (legacy) Markdown would treat as formatting
+func escapeMarkdown(text string) string {
+	return strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[").Replace(text)
+}
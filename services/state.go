@@ -0,0 +1,81 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// StateStore remembers the newest transaction time seen per account,
+// so a restart picks up where the tracker stopped instead of skipping the gap
+type StateStore struct {
+	mu       sync.Mutex
+	path     string
+	lastTime map[string]int64
+}
+
+// OpenState loads the state file (a missing file is an empty state)
+func OpenState(path string) (*StateStore, error) {
+	s := &StateStore{path: path, lastTime: make(map[string]int64)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stored struct {
+		LastTime map[string]int64 `json:"last_time"`
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	for label, ts := range stored.LastTime {
+		s.lastTime[label] = ts
+	}
+	return s, nil
+}
+
+// LastTime returns the saved position of an account
+func (s *StateStore) LastTime(label string) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ts, ok := s.lastTime[label]
+	return ts, ok
+}
+
+// SetLastTime moves an account's position forward and saves the file
+func (s *StateStore) SetLastTime(label string, ts int64) error {
+	s.mu.Lock()
+	s.lastTime[label] = ts
+	s.mu.Unlock()
+
+	return s.Save()
+}
+
+// Save writes the state atomically (temp file + rename)
+func (s *StateStore) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.MarshalIndent(struct {
+		LastTime map[string]int64 `json:"last_time"`
+	}{s.lastTime}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
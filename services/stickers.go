@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+)
+
+// How long a looked up sticker price is reused
+const stickerPriceTTL = 6 * time.Hour
+
+// Sticker prices by market title, shared by all accounts
+var stickerPrices = struct {
+	sync.Mutex
+	byTitle map[string]cachedPrice
+}{byTitle: make(map[string]cachedPrice)}
+
+type cachedPrice struct {
+	price     types.Money
+	fetchedAt time.Time
+}
+
+// formatApplied renders the stickers, charms and name tag of an item:
+//
+//	Stickers: Crown (Foil) #1, Titan | Katowice 2014 #2 (35% scraped)
+//	Charm: Lil' Ava
+//	Name tag: "Big boy"
+//	Stickers value: 1234.56 $
+func formatApplied(ctx context.Context, stickers, keychains []types.Sticker, nameTag string, cfg types.AccountConfig) string {
+	var applied strings.Builder
+	if len(stickers) > 0 {
+		applied.WriteString("\nStickers: " + escapeMarkdown(formatStickers(stickers)))
+	}
+	if len(keychains) > 0 {
+		label := "Charm"
+		if len(keychains) > 1 {
+			label = "Charms"
+		}
+		applied.WriteString(fmt.Sprintf("\n%s: %s", label, escapeMarkdown(formatStickers(keychains))))
+	}
+	if nameTag != "" {
+		applied.WriteString(fmt.Sprintf("\nName tag: \"%s\"", escapeMarkdown(nameTag)))
+	}
+
+	if cfg.StickerPrices != "" && len(stickers) > 0 {
+		value, priced := StickerValue(ctx, cfg, stickers)
+		if priced > 0 {
+			applied.WriteString(fmt.Sprintf("\nStickers value: %s", displayAmount(value, cfg)))
+			if priced < len(stickers) {
+				applied.WriteString(fmt.Sprintf(" (%d/%d priced)", priced, len(stickers)))
+			}
+		}
+	}
+	return applied.String()
+}
+
+// formatStickers lists names with their slot and scrape: "Crown (Foil) #1 (35% scraped)" (plain text)
+func formatStickers(stickers []types.Sticker) string {
+	parts := make([]string, 0, len(stickers))
+	for _, sticker := range stickers {
+		part := sticker.Name
+		if sticker.Slot != nil {
+			part += fmt.Sprintf(" #%d", *sticker.Slot+1)
+		}
+		if sticker.Wear > 0 {
+			part += fmt.Sprintf(" (%.0f%% scraped)", sticker.Wear*100)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// StickerValue adds up the lowest market price of every applied sticker (scrape is ignored).
+// Returns how many stickers had a price. Prices are cached for stickerPriceTTL, lookups share
+// the public market limiter and stop when ctx is cancelled.
+func StickerValue(ctx context.Context, cfg types.AccountConfig, stickers []types.Sticker) (types.Money, int) {
+	ctx, cancel := context.WithTimeout(WithAccount(ctx, cfg.Label), 20*time.Second)
+	defer cancel()
+
+	total := types.USD(0)
+	priced := 0
+	for _, sticker := range stickers {
+		price, err := stickerPrice(ctx, stickerTitle(sticker.Name))
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			Logger(ctx).Warn("Sticker price lookup failed", "sticker", sticker.Name, "error", err)
+			continue
+		}
+		if price.Cents > 0 {
+			total = total.Add(price)
+			priced++
+		}
+	}
+	return total, priced
+}
+
+// stickerTitle is the market title of a sticker ("Crown (Foil)" -> "Sticker | Crown (Foil)")
+func stickerTitle(name string) string {
+	if strings.HasPrefix(name, "Sticker | ") {
+		return name
+	}
+	return "Sticker | " + name
+}
+
+// stickerPrice returns the cached price or looks it up on the DMarket market (zero if nobody sells it)
+func stickerPrice(ctx context.Context, title string) (types.Money, error) {
+	stickerPrices.Lock()
+	cached, ok := stickerPrices.byTitle[title]
+	stickerPrices.Unlock()
+	if ok && time.Since(cached.fetchedAt) < stickerPriceTTL {
+		return cached.price, nil
+	}
+
+	price, err := FetchLowestMarketPrice(ctx, title)
+	if err != nil {
+		return types.Money{}, err
+	}
+
+	stickerPrices.Lock()
+	stickerPrices.byTitle[title] = cachedPrice{price: price, fetchedAt: time.Now()}
+	stickerPrices.Unlock()
+	return price, nil
+}
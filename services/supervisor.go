@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/cyberbebebe/dmarket-transactions-poster/types"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// AccountHandle holds an account's live config. Workers re-read it on every poll,
+// so notification settings changed by a reload apply without a restart.
+type AccountHandle struct {
+	cfg      atomic.Pointer[types.AccountConfig]
+	degraded atomic.Bool
+}
+
+func NewAccountHandle(cfg types.AccountConfig) *AccountHandle {
+	h := &AccountHandle{}
+	h.cfg.Store(&cfg)
+	return h
+}
+
+func (h *AccountHandle) Config() types.AccountConfig {
+	return *h.cfg.Load()
+}
+
+func (h *AccountHandle) Update(cfg types.AccountConfig) {
+	h.cfg.Store(&cfg)
+}
+
+// Degraded reports whether the account is tracking without a working Telegram bot
+func (h *AccountHandle) Degraded() bool {
+	return h.degraded.Load()
+}
+
+// setDegraded records the bot state and logs when it changes
+func (h *AccountHandle) setDegraded(degraded bool) {
+	if h.degraded.Swap(degraded) == degraded {
+		return
+	}
+	if degraded {
+		slog.Warn("Degraded: Telegram bot unavailable, messages are only logged", "account", h.Config().Label)
+	} else {
+		slog.Info("Telegram bot is back, no longer degraded", "account", h.Config().Label)
+	}
+}
+
+// How often a broken token is retried
+const botRetryInterval = time.Minute
+
+// BotRegistry shares one bot per token and wakes up bots for tokens added by a reload
+type BotRegistry struct {
+	mu       sync.Mutex
+	bots     map[string]*tgbotapi.BotAPI
+	failedAt map[string]time.Time
+}
+
+func NewBotRegistry(bots map[string]*tgbotapi.BotAPI) *BotRegistry {
+	if bots == nil {
+		bots = make(map[string]*tgbotapi.BotAPI)
+	}
+	return &BotRegistry{bots: bots, failedAt: make(map[string]time.Time)}
+}
+
+// Get returns the bot for a token, creating it if needed (nil if the token is empty or broken).
+// A broken token is retried at most once per botRetryInterval.
+func (r *BotRegistry) Get(token string) *tgbotapi.BotAPI {
+	if token == "" {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if bot, ok := r.bots[token]; ok {
+		return bot
+	}
+	if at, failed := r.failedAt[token]; failed && time.Since(at) < botRetryInterval {
+		return nil
+	}
+	bot, err := tgbotapi.NewBotAPI(token)
+	if err != nil {
+		slog.Error("Telegram bot init failed", "error", err)
+		r.failedAt[token] = time.Now()
+		return nil
+	}
+	delete(r.failedAt, token)
+	r.bots[token] = bot
+	slog.Info("Bot live", "bot", bot.Self.UserName)
+	return bot
+}
+
+// Shared is what every worker of every account uses
+type Shared struct {
+	Costs       types.CostMap
+	CostMu      *sync.RWMutex
+	Ledger      *Ledger
+	Bots        *BotRegistry
+	Outbox      *Outbox
+	State       *StateStore
+	Health      *Health
+	Balances    *BalanceStore
+	ManualCosts *ManualCosts
+}
+
+// Supervisor runs one tracker (plus balance and CSFloat pollers) per account and reconciles them with the config
+type Supervisor struct {
+	mu        sync.Mutex
+	workers   map[string]*accountWorker // By label
+	listeners map[string]bool           // Bot tokens with a command listener
+	started   bool
+
+	shared *Shared
+	wg     *sync.WaitGroup
+}
+
+type accountWorker struct {
+	handle *AccountHandle
+	cancel context.CancelFunc
+}
+
+func NewSupervisor(shared *Shared, wg *sync.WaitGroup) *Supervisor {
+	return &Supervisor{
+		workers:   make(map[string]*accountWorker),
+		listeners: make(map[string]bool),
+		shared:    shared,
+		wg:        wg,
+	}
+}
+
+// Apply starts new accounts, stops removed ones, restarts accounts whose keys changed
+// and hands every other change to the running workers. The cost map is kept.
+func (s *Supervisor) Apply(ctx context.Context, configs []types.AccountConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wanted := make(map[string]types.AccountConfig)
+	for _, cfg := range configs {
+		wanted[cfg.Label] = cfg
+	}
+
+	// 1. Stop removed accounts and accounts with new keys
+	for label, worker := range s.workers {
+		cfg, keep := wanted[label]
+		old := worker.handle.Config()
+		if keep && cfg.DMarketKey == old.DMarketKey && cfg.CSFloatKey == old.CSFloatKey {
+			continue
+		}
+		slog.Info("Stopping tracker", "account", label)
+		worker.cancel()
+		delete(s.workers, label)
+		s.shared.Health.Remove(label)
+	}
+
+	// 2. Start new ones, update the rest in place
+	for _, cfg := range configs {
+		if worker, running := s.workers[cfg.Label]; running {
+			worker.handle.Update(cfg)
+			continue
+		}
+		s.start(ctx, cfg)
+	}
+
+	// 3. Command listener for every bot in use
+	for _, cfg := range configs {
+		if cfg.DryRun || cfg.TelegramToken == "" || s.listeners[cfg.TelegramToken] {
+			continue
+		}
+		if bot := s.shared.Bots.Get(cfg.TelegramToken); bot != nil {
+			s.listeners[cfg.TelegramToken] = true
+			s.wg.Add(1)
+			go StartCommandListener(ctx, bot, s.Accounts, s.shared, s.wg)
+		}
+	}
+
+	s.started = true
+}
+
+// Accounts returns the configs of the running accounts
+func (s *Supervisor) Accounts() []types.AccountConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	configs := make([]types.AccountConfig, 0, len(s.workers))
+	for _, worker := range s.workers {
+		configs = append(configs, worker.handle.Config())
+	}
+	return configs
+}
+
+// start launches the workers of one account, caller holds the lock
+func (s *Supervisor) start(ctx context.Context, cfg types.AccountConfig) {
+	workerCtx, cancel := context.WithCancel(WithAccount(ctx, cfg.Label))
+	handle := NewAccountHandle(cfg)
+	s.workers[cfg.Label] = &accountWorker{handle: handle, cancel: cancel}
+	s.shared.Health.Start(cfg)
+
+	// Accounts added while running need their buys in the shared map first
+	loadCosts := s.started
+
+	s.wg.Add(1)
+	go func() {
+		if loadCosts {
+			LoadAccountCosts(workerCtx, cfg, s.shared.Costs, s.shared.CostMu)
+		}
+		StartTracker(workerCtx, handle, s.shared, s.wg)
+	}()
+
+	s.wg.Add(1)
+	go StartBalancePoller(workerCtx, handle, s.shared, s.wg)
+
+	s.wg.Add(1)
+	go StartReleaseDigest(workerCtx, handle, s.shared, s.wg)
+
+	if cfg.CSFloatKey != "" {
+		s.wg.Add(1)
+		go StartCSFloatPoller(workerCtx, handle, s.shared.Costs, s.shared.CostMu, s.wg)
+	}
+}
+
+// WatchConfig calls reload+apply when the config file changes or on SIGHUP, until ctx is done.
+// A config that fails to load is reported and the running accounts are kept.
+func WatchConfig(ctx context.Context, path string, reload func() ([]types.AccountConfig, error), apply func([]types.AccountConfig)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	lastMod := modTime(path)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			slog.Info("SIGHUP, reloading config")
+		case <-ticker.C:
+			mod := modTime(path)
+			if mod.Equal(lastMod) {
+				continue
+			}
+			slog.Info("Config changed, reloading", "path", path)
+		}
+		lastMod = modTime(path)
+
+		configs, err := reload()
+		if err != nil {
+			slog.Error("Config reload failed, keeping current accounts", "error", err)
+			continue
+		}
+		apply(configs)
+	}
+}
+
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -1,17 +1,20 @@
 package services
 
 import (
-	"fmt"
+	"log/slog"
 
 	"github.com/cyberbebebe/dmarket-transactions-poster/types"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 // WakeUpBots initializes a bot instance for every unique token in the config.
-func WakeUpBots(configs []types.AccountConfig) (map[string]*tgbotapi.BotAPI, error) {
+// Accounts whose bot failed are returned in failed (by label), the caller decides whether to go on.
+func WakeUpBots(configs []types.AccountConfig) (map[string]*tgbotapi.BotAPI, map[string]error) {
 	botMap := make(map[string]*tgbotapi.BotAPI)
+	failed := make(map[string]error)
+	tokenErrors := make(map[string]error)
 
-	fmt.Println("Waking up Telegram Bots...")
+	slog.Info("Waking up Telegram bots")
 
 	for _, cfg := range configs {
 		token := cfg.TelegramToken
@@ -19,27 +22,26 @@ func WakeUpBots(configs []types.AccountConfig) (map[string]*tgbotapi.BotAPI, err
 			continue
 		}
 
+		// Same broken token, same error
+		if err, broken := tokenErrors[token]; broken {
+			failed[cfg.Label] = err
+			continue
+		}
+
 		// Only wake up if we haven't already
 		if _, exists := botMap[token]; !exists {
 			bot, err := tgbotapi.NewBotAPI(token)
-			
-			// --- ERROR HANDLING WITH PAUSE ---
 			if err != nil {
-				fmt.Printf("\nCRITICAL ERROR: Failed to init bot for account '%s'\n", cfg.Label)
-				fmt.Printf("Reason: %v\n", err)
-				fmt.Println("(Likely a wrong Telegram Token in config.json)")
-				
-				fmt.Println("\nPress [ENTER] to exit program...")
-				var input string
-				fmt.Scanln(&input) // <--- The Pause You Requested
-				
-				return nil, err
+				slog.Error("Telegram bot init failed (likely a wrong telegram_token)", "account", cfg.Label, "error", err)
+
+				tokenErrors[token] = err
+				failed[cfg.Label] = err
+				continue
 			}
-			// ---------------------------------
 
 			botMap[token] = bot
-			fmt.Printf("   > Bot live: %s\n", bot.Self.UserName)
+			slog.Info("Bot live", "bot", bot.Self.UserName)
 		}
 	}
-	return botMap, nil
-}
\ No newline at end of file
+	return botMap, failed
+}
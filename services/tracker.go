@@ -1,9 +1,10 @@
 package services
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"math"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -12,18 +13,44 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-// StartTracker is the main loop for a single DMarket account.
-func StartTracker(cfg types.AccountConfig, bot *tgbotapi.BotAPI, costs types.CostMap, mu *sync.RWMutex, wg *sync.WaitGroup) {
+// StartTracker is the main loop for a single DMarket account. It stops when ctx is cancelled.
+// The config is re-read from the handle on every poll.
+func StartTracker(ctx context.Context, handle *AccountHandle, shared *Shared, wg *sync.WaitGroup) {
 	defer wg.Done()
-	fmt.Printf("[%s] Tracker Started\n", cfg.Label)
+	ctx = WithAccount(ctx, handle.Config().Label)
+	log := Logger(ctx)
+	log.Info("Tracker started")
+	defer log.Info("Tracker stopped")
 
+	costs, mu := shared.Costs, shared.CostMu
+
+	// Resume from the saved position (first run starts from now)
 	lastTime := time.Now().Unix()
+	if saved, ok := shared.State.LastTime(handle.Config().Label); ok {
+		lastTime = saved
+	}
 
 	for {
+		cfg := handle.Config()
+		var bot *tgbotapi.BotAPI
+		if !cfg.DryRun {
+			bot = shared.Bots.Get(cfg.TelegramToken)
+			handle.setDegraded(cfg.TelegramToken != "" && bot == nil)
+		}
+
 		// 1. Fetch History
-		newTxs, nextTime, err := FetchNewTransactions(cfg.DMarketKey, lastTime)
+		pollStart := time.Now()
+		newTxs, nextTime, err := FetchNewTransactions(ctx, cfg.DMarketKey, lastTime, cfg.HistoryLimit)
 		if err != nil {
-			time.Sleep(15 * time.Second)
+			if ctx.Err() == nil {
+				log.Warn("Fetching transactions failed", "error", err)
+				shared.Health.Report(cfg, err, handle.Degraded())
+				polls.WithLabelValues(cfg.Label, "error").Inc()
+				pollDuration.WithLabelValues(cfg.Label).Observe(time.Since(pollStart).Seconds())
+			}
+			if !sleepContext(ctx, time.Duration(cfg.PollInterval)) {
+				return
+			}
 			continue
 		}
 
@@ -32,130 +59,210 @@ func StartTracker(cfg types.AccountConfig, bot *tgbotapi.BotAPI, costs types.Cos
 			var currentBalance types.UserBalanceResponse
 			
 			if cfg.AdvancedBalance {
-				currentBalance, _ = FetchUserBalance(cfg.DMarketKey)
+				currentBalance, _ = FetchUserBalance(ctx, cfg.DMarketKey)
 				}
 
 			for _, tx := range newTxs {
+				transactionsSeen.WithLabelValues(cfg.Label, tx.Type, tx.Status).Inc()
 
-				if cfg.IgnoreReleased {
+				// Tell the balance poller what this moved (status updates are older than the last poll)
+				shared.Balances.Expect(cfg.Label, tx, tx.CreatedAt > lastTime, RateProviderFor(cfg))
 
-					// Skip success transactions that were trade protected, if true in config
-					isOldTrade := tx.UpdatedAt > tx.CreatedAt 
-
-					if tx.Status == "success" && isOldTrade {
-						continue
+				// Record every status change (before the cost map learns about this tx)
+				entry := NewLedgerEntry(cfg.Label, tx, costs, mu, RateProviderFor(cfg))
+				if !cfg.DryRun {
+					if err := shared.Ledger.Record(entry); err != nil {
+						log.Error("Ledger write failed", "tx", tx.ID, "error", err)
 					}
 				}
 
+				flow := ActivityFlow(tx)
+
+				// Skip success transactions that were trade protected, if true in config
+				released := cfg.IgnoreReleased && flow == FlowTrade && tx.Status == "success" && tx.UpdatedAt > tx.CreatedAt
+
+				// Formatted once for the rules and the post (sticker values are looked up here)
+				var text string
+				switch {
+				case flow != FlowTrade:
+					text = FormatMovement(ctx, tx, cfg, currentBalance)
+				case len(cfg.Rules) > 0 || !released:
+					text = FormatTransaction(ctx, tx, cfg, costs, mu, currentBalance)
+				}
+
+				// Alert rules see every transaction, even the ones not posted
+				if len(cfg.Rules) > 0 {
+					ApplyRules(ctx, shared, cfg, tx, entry, text, wg)
+				}
+
+				// Deposits, withdrawals, refunds and item transfers have their own message
+				if flow != FlowTrade {
+					PostMovement(ctx, shared.Outbox, bot, tx, cfg, text)
+					transactionsPosted.WithLabelValues(cfg.Label, tx.Type, tx.Status).Inc()
+					continue
+				}
+
+				if released {
+					transactionsSkipped.WithLabelValues(cfg.Label, tx.Type, tx.Status).Inc()
+					continue
+				}
+
 				// Update Cost Map if we bought something
 				if tx.Type == "target_closed" || tx.Type == "purchase" {
-					amount, _ := strconv.ParseFloat(tx.Changes[0].Money.Amount, 64)
-					if tx.Details.ItemID != "" {
+					if tx.Details.ItemID != "" && len(tx.Changes) > 0 {
 						mu.Lock()
-						costs[tx.Details.ItemID] = amount
+						// A marked price survives status updates of its purchase, only a purchase created after the mark replaces it
+						if existing := costs[tx.Details.ItemID]; !existing.Manual || tx.CreatedAt > existing.MarkedAt {
+							costs[tx.Details.ItemID] = types.CostEntry{Price: tx.Changes[0].Money, AcquiredAt: tx.CreatedAt}
+						}
 						mu.Unlock()
 					}
 				}
 
 				// Post it
-				PostTransaction(bot, tx, cfg, costs, mu, currentBalance)
+				PostTransaction(ctx, shared.Outbox, bot, tx, cfg, text)
+				transactionsPosted.WithLabelValues(cfg.Label, tx.Type, tx.Status).Inc()
 			}
 			lastTime = nextTime
+
+			if !cfg.DryRun {
+				if err := shared.State.SetLastTime(cfg.Label, lastTime); err != nil {
+					log.Error("Saving state failed", "error", err)
+				}
+			}
 		}
 
-		time.Sleep(15 * time.Second)
+		shared.Health.Report(cfg, nil, handle.Degraded())
+		polls.WithLabelValues(cfg.Label, "ok").Inc()
+		pollDuration.WithLabelValues(cfg.Label).Observe(time.Since(pollStart).Seconds())
+
+		if !sleepContext(ctx, time.Duration(cfg.PollInterval)) {
+			return
+		}
+	}
+}
+
+// sleepContext waits for d, returns false if ctx was cancelled first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
 	}
 }
 
-// PostTransaction handles formatting and sending
-func PostTransaction(bot *tgbotapi.BotAPI, tx types.Transaction, cfg types.AccountConfig, costs types.CostMap, mu *sync.RWMutex, liveBalance types.UserBalanceResponse) {
+// PostTransaction queues a trade formatted by FormatTransaction on the outbox
+func PostTransaction(ctx context.Context, outbox *Outbox, bot *tgbotapi.BotAPI, tx types.Transaction, cfg types.AccountConfig, message string) {
+	deliverItemMessage(ctx, outbox, bot, cfg, tx, message)
+}
+
+// FormatTransaction renders a trade: action and status, item details, then the money block
+func FormatTransaction(ctx context.Context, tx types.Transaction, cfg types.AccountConfig, costs types.CostMap, mu *sync.RWMutex, liveBalance types.UserBalanceResponse) string {
 	
 	// 1. Prepare Builders
 	var metaData strings.Builder
 	var moneyData strings.Builder
 
 	// 2. Parse Basic Data
-	change, _ := strconv.ParseFloat(tx.Changes[0].Money.Amount, 64)
+	var change types.Money
+	if len(tx.Changes) > 0 {
+		change = tx.Changes[0].Money
+	}
 	
 	// Balance Logic (Snapshot vs Live)
-	var balanceVal float64
-	var pendingVal float64
+	var balanceVal types.Money
+	var pendingVal types.Money
 
 	if cfg.AdvancedBalance && liveBalance.Usd != "" {
-		b, _ := strconv.ParseFloat(liveBalance.Usd, 64)
-		p, _ := strconv.ParseFloat(liveBalance.UsdTradeProtected, 64)
-		balanceVal = b / 100
-		pendingVal = p / 100
+		// Live endpoint returns cents
+		balanceVal = liveBalance.Available()
+		pendingVal = liveBalance.TradeProtected()
 	} else {
-		b, _ := strconv.ParseFloat(tx.Balance.Amount, 64)
-		balanceVal = b
+		balanceVal = tx.Balance
 	}
 
 	// 3. Logic: Signs, Fees, and Profit
 	moneySign := "-"
 	statusFix := fixMarkdownV2(tx.Status)
 	showProfit := false
-	profit := 0.0
+	var profit types.Money
 	profitP := 0.0
 	profitSign := ""
+	heldSeconds := int64(0)
 
 	if tx.Action == "Sell" {
 		moneySign = "+"
 		
 		// Fee Deduction (Only needed if NOT using advanced/live balance)
 		if !cfg.AdvancedBalance {
-			deduction := math.Round((change * 0.02) * 100) / 100
-			balanceVal = balanceVal - deduction
+			balanceVal = balanceVal.Sub(sellFee(change))
 			if tx.Status == "trade_protected" {
-				balanceVal = balanceVal - change
+				balanceVal = balanceVal.Sub(change)
 			}
 		}
 
 		// Calculate Profit
 		if tx.Details.ItemID != "" {
 			mu.RLock()
-			buyPrice, found := costs[tx.Details.ItemID]
+			cost, found := costs[tx.Details.ItemID]
 			mu.RUnlock()
+			buyPrice := cost.Price
+
+			// Buy may have been paid in another currency
+			if found && buyPrice.CurrencyCode() != change.CurrencyCode() {
+				converted, err := ConvertMoney(buyPrice, change.CurrencyCode(), RateProviderFor(cfg))
+				if err != nil {
+					Logger(ctx).Warn("FX conversion failed", "error", err)
+					found = false
+				}
+				buyPrice = converted
+			}
 
-			if found && buyPrice > 0 {
-				profit = change - buyPrice
-				profitP = (profit / buyPrice) * 100
+			if found && buyPrice.Cents > 0 {
+				profit = change.Sub(buyPrice)
+				profitP = profit.Percent(buyPrice)
 				showProfit = true
 				
 				profitSign = "-"
-				if profit >= 0 { profitSign = "+" }
+				if profit.Cents >= 0 { profitSign = "+" }
+
+				if cost.AcquiredAt > 0 && tx.CreatedAt > cost.AcquiredAt {
+					heldSeconds = tx.CreatedAt - cost.AcquiredAt
+				}
 			}
 		}
 	}
 
 	// 4. Build "Details Block" (The middle part)
-	if tx.Details.Extra.FloatValue != 0.0 {
-		metaData.WriteString(fmt.Sprintf("\n\nFloat: %.8f", tx.Details.Extra.FloatValue))
-	}
-	if tx.Details.Extra.PhaseTitle != "" {
-		metaData.WriteString(fmt.Sprintf("\nPhase: %s", tx.Details.Extra.PhaseTitle))
-	}
-	if tx.Details.Extra.PaintSeed != nil {
-		metaData.WriteString(fmt.Sprintf("\nPattern: %d", *tx.Details.Extra.PaintSeed))
-	}
+	metaData.WriteString(itemDetails(ctx, tx, cfg))
 
 	// 5. "Money Block"
 	// Change: + 25.00 $
-	moneyData.WriteString(fmt.Sprintf("Change: %s %.2f $", moneySign, change))
+	moneyData.WriteString(fmt.Sprintf("Change: %s %s", moneySign, displayAmount(change.Abs(), cfg)))
 
 	// Profit: + 5.00 $ (+ 20.0 %)
 	if showProfit {
-		profitStr := fmt.Sprintf("\nProfit: %s %.2f $", profitSign, math.Abs(profit))
+		profitStr := fmt.Sprintf("\nProfit: %s %s", profitSign, displayAmount(profit.Abs(), cfg))
 		if cfg.ProfitPercent {
 			profitStr += fmt.Sprintf(" / %s %.2f %%", profitSign, math.Abs(profitP))
 		}
 		moneyData.WriteString(profitStr)
 	}
 
+	// Held: 12d 4h (+ 0.42 $/day)
+	if cfg.HoldingTime && heldSeconds > 0 {
+		perDay := profit.MulRate(86400 / float64(heldSeconds))
+		moneyData.WriteString(fmt.Sprintf("\nHeld: %s (%s %s/day)", formatAge(heldSeconds), profitSign, displayAmount(perDay.Abs(), cfg)))
+	}
+
 	// Balance: 100.00 $ / 50.00 $
-	balanceStr := fmt.Sprintf("\nBalance: %.2f $", balanceVal)
-	if cfg.AdvancedBalance && pendingVal > 0 {
-		balanceStr += fmt.Sprintf(" / %.2f $", pendingVal)
+	balanceStr := fmt.Sprintf("\nBalance: %s", displayAmount(balanceVal, cfg))
+	if cfg.AdvancedBalance && pendingVal.Cents > 0 {
+		balanceStr += fmt.Sprintf(" / %s", displayAmount(pendingVal, cfg))
 	}
 	moneyData.WriteString(balanceStr)
 
@@ -163,24 +270,77 @@ func PostTransaction(bot *tgbotapi.BotAPI, tx types.Transaction, cfg types.Accou
 	moneyBlock := moneyData.String()
 
 	// 6. Final Assembly
-	message := fmt.Sprintf("%s %s\n`%s`%s\n\n%s",
+	return fmt.Sprintf("%s %s\n`%s`%s\n\n%s",
 		tx.Action,
 		statusFix,
 		tx.Subject,
 		detailsBlock,
 		moneyBlock,
 	)
+}
 
-	// 7. Send
+// itemDetails is the float/phase/pattern block of an item plus what is applied to it ("" if the item has none)
+func itemDetails(ctx context.Context, tx types.Transaction, cfg types.AccountConfig) string {
+	var details strings.Builder
+	if tx.Details.Extra.FloatValue != 0.0 {
+		details.WriteString(fmt.Sprintf("\n\nFloat: %.8f", tx.Details.Extra.FloatValue))
+	}
+	if tx.Details.Extra.PhaseTitle != "" {
+		details.WriteString(fmt.Sprintf("\nPhase: %s", tx.Details.Extra.PhaseTitle))
+	}
+	if tx.Details.Extra.PaintSeed != nil {
+		details.WriteString(fmt.Sprintf("\nPattern: %d", *tx.Details.Extra.PaintSeed))
+	}
+
+	extra := tx.Details.Extra
+	applied := formatApplied(ctx, extra.Stickers, extra.Keychains, extra.NameTag, cfg)
+	if applied != "" && details.Len() == 0 {
+		// Items without float (e.g. an agent with patches) still get the blank line before
+		applied = "\n" + applied
+	}
+	details.WriteString(applied)
+	return details.String()
+}
+
+// deliverMessage prints the message in dry-run, logs it without a bot, otherwise queues it (Markdown)
+func deliverMessage(outbox *Outbox, bot *tgbotapi.BotAPI, cfg types.AccountConfig, message string) {
+	deliverWithButtons(outbox, bot, cfg, message, nil)
+}
+
+// deliverWithButtons is deliverMessage with an optional inline keyboard under the message
+func deliverWithButtons(outbox *Outbox, bot *tgbotapi.BotAPI, cfg types.AccountConfig, message string, keyboard *tgbotapi.InlineKeyboardMarkup) {
+	if cfg.DryRun {
+		fmt.Printf("[%s] Message:\n%s\n", cfg.Label, message)
+		if keyboard != nil {
+			fmt.Printf("%s\n", formatButtons(keyboard))
+		}
+		fmt.Println()
+		return
+	}
+	if bot == nil {
+		slog.Warn("No Telegram bot, message not sent", "account", cfg.Label, "text", message)
+		return
+	}
 	msg := tgbotapi.NewMessageToChannel(cfg.TelegramChatID, message)
 	msg.ParseMode = "Markdown"
-	go func() {
-        if _, err := bot.Send(msg); err != nil {
-            fmt.Printf("[%s] Telegram Error: %v\n", cfg.Label, err)
-        }
-    }()
+	if keyboard != nil {
+		msg.ReplyMarkup = *keyboard
+	}
+	outbox.Send(cfg.Label, bot, msg)
 }
 
+// sellFee is the marketplace fee (2%) taken from a sale, rounded to cents
+func sellFee(amount types.Money) types.Money {
+	return amount.MulRate(0.02)
+}
+
+// signed formats money with an explicit sign and its currency: "+12.34 $" / "-5.00 €"
+func signed(m types.Money) string {
+	if m.Cents >= 0 {
+		return "+" + formatAmount(m)
+	}
+	return formatAmount(m)
+}
 
 func fixMarkdownV2(text string) string {
 	replacer := strings.NewReplacer(
@@ -1,30 +1,22 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
 
 	"github.com/cyberbebebe/dmarket-transactions-poster/types"
 )
 
 // FetchNewTransactions gets history items strictly NEWER than lastTimestamp.
-func FetchNewTransactions(secretKey string, lastTimestamp int64) ([]types.Transaction, int64, error) {
+func FetchNewTransactions(ctx context.Context, secretKey string, lastTimestamp int64, limit int) ([]types.Transaction, int64, error) {
 	var newTransactions []types.Transaction
 	
-	// We ask for the last 50 and filter manually
-	endpoint := "/exchange/v1/history?version=V3&limit=50&activities=sell,purchase,target_closed&statuses=success,trade_protected,reverted"
-	
-	method := "GET"
-	rootApiUrl := "https://api.dmarket.com"
-	client := &http.Client{}
-
-	headers, _ := generateHeaders(secretKey, method, endpoint, nil)
-	req, _ := http.NewRequest(method, rootApiUrl+endpoint, nil)
-	req.Header = headers
+	// We ask for the last N and filter manually
+	endpoint := fmt.Sprintf("/exchange/v1/history?version=V3&limit=%d&activities=%s&statuses=success,trade_protected,reverted", limit, trackedActivities)
 
-	resp, err := client.Do(req)
+	resp, err := dmarketRequest(ctx, secretKey, "GET", endpoint)
 	if err != nil {
 		return nil, lastTimestamp, err
 	}
@@ -56,19 +48,73 @@ func FetchNewTransactions(secretKey string, lastTimestamp int64) ([]types.Transa
 	return newTransactions, newestTS, nil
 }
 
+// Activities the live tracker posts: trades plus cash and item movements
+const trackedActivities = "sell,purchase,target_closed,deposit,withdraw,item_deposit,item_withdraw,refund"
+
+// Every /history activity type, used for full exports and backfills
+const allHistoryActivities = "sell,purchase,target_closed,instant_sell,deposit,withdraw,item_deposit,item_withdraw,refund"
+
+// FetchFullHistory pages through the whole /history (all activities), oldest first.
+// Pages stop once transactions are older than since (0 = everything).
+func FetchFullHistory(ctx context.Context, secretKey string, since int64) ([]types.Transaction, error) {
+	var history []types.Transaction
+
+	limit := 100
+	offset := 0
+
+	for {
+		// Sorted by createdAt (newest first) so we can stop at "since"
+		endpoint := fmt.Sprintf("/exchange/v1/history?version=V3&limit=%d&offset=%d&sortBy=createdAt&activities=%s", limit, offset, allHistoryActivities)
+
+		resp, err := dmarketRequest(ctx, secretKey, "GET", endpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != 200 {
+			body := errorBody(resp.Body)
+			resp.Body.Close()
+			return nil, fmt.Errorf("API Error %d: %s", resp.StatusCode, body)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		var response types.TransactionsResponse
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, fmt.Errorf("unmarshal error: %v", err)
+		}
+
+		reachedSince := false
+		for _, tx := range response.Objects {
+			if since > 0 && tx.CreatedAt < since {
+				reachedSince = true
+				break
+			}
+			history = append(history, tx)
+		}
+
+		offset += len(response.Objects)
+		if reachedSince || len(response.Objects) < limit || (response.Total > 0 && offset >= response.Total) {
+			break
+		}
+	}
+
+	// Oldest first
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+		history[i], history[j] = history[j], history[i]
+	}
+
+	return history, nil
+}
+
 // FetchUserBalance to get Real + Pending balance.
-func FetchUserBalance(secretKey string) (types.UserBalanceResponse, error) {
+func FetchUserBalance(ctx context.Context, secretKey string) (types.UserBalanceResponse, error) {
 	var balance types.UserBalanceResponse
 	
 	endpoint := "/account/v1/balance"
-	rootApiUrl := "https://api.dmarket.com"
-	client := &http.Client{}
-
-	headers, _ := generateHeaders(secretKey, "GET", endpoint, nil)
-	req, _ := http.NewRequest("GET", rootApiUrl+endpoint, nil)
-	req.Header = headers
 
-	resp, err := client.Do(req)
+	resp, err := dmarketRequest(ctx, secretKey, "GET", endpoint)
 	if err != nil {
 		return balance, err
 	}
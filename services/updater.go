@@ -1,26 +1,28 @@
 package services
 
 import (
-	"fmt"
+	"context"
 	"sync"
 	"time"
 
 	"github.com/cyberbebebe/dmarket-transactions-poster/types"
 )
 
-// StartCSFloatPoller periodically syncs CSFloat buys for a specific account.
-func StartCSFloatPoller(cfg types.AccountConfig, costs types.CostMap, mu *sync.RWMutex, wg *sync.WaitGroup) {
+// StartCSFloatPoller periodically syncs CSFloat buys for a specific account until ctx is cancelled.
+func StartCSFloatPoller(ctx context.Context, handle *AccountHandle, costs types.CostMap, mu *sync.RWMutex, wg *sync.WaitGroup) {
 	defer wg.Done()
 	
-	fmt.Printf("[%s] CSFloat Auto-Updater Active\n", cfg.Label)
+	Logger(ctx).Info("CSFloat auto-updater active")
 
 	for {
 		// 1. Sleep for X minutes (e.g., 30 minutes)
 		// We sleep FIRST because we already ran an initial sync in main.go/InitCostBasis
-		time.Sleep(72 * time.Hour)
+		if !sleepContext(ctx, 72*time.Hour) {
+			return
+		}
 
 		// 2. Run Sync
 		// This uses the function we wrote in cost_basis.go
-		SyncCSFloatCosts(cfg, costs, mu)
+		SyncCSFloatCosts(ctx, handle.Config(), costs, mu)
 	}
-}
\ No newline at end of file
+}
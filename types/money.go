@@ -0,0 +1,220 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Money is a fixed-point amount in cents, so sums never drift like float64 does.
+type Money struct {
+	Cents    int64
+	Currency string // ISO code, empty means USD
+}
+
+// USD builds a dollar amount from cents (CSFloat prices, DMarket balance endpoint)
+func USD(cents int64) Money {
+	return Money{Cents: cents, Currency: "USD"}
+}
+
+// ParseMoney parses a decimal amount in major units ("12.34", "-5", "0.5")
+func ParseMoney(amount, currency string) (Money, error) {
+	amount = strings.TrimSpace(amount)
+	if amount == "" {
+		return Money{Currency: currency}, nil
+	}
+	if strings.ContainsAny(amount, "eE") {
+		// Exponent form ("1.5e2"), as json.Number keeps it: rewrite exactly as a plain decimal
+		exact, ok := new(big.Rat).SetString(amount)
+		if !ok {
+			return Money{}, fmt.Errorf("invalid amount %q", amount)
+		}
+		amount = exact.FloatString(8)
+	}
+
+	negative := strings.HasPrefix(amount, "-")
+	amount = strings.TrimLeft(amount, "+-")
+
+	whole, frac, _ := strings.Cut(amount, ".")
+	if !isDigits(whole) || !isDigits(frac) || (whole == "" && frac == "") {
+		return Money{}, fmt.Errorf("invalid amount %q", amount)
+	}
+	if len(frac) > 2 {
+		// Round half up on the third decimal
+		roundUp := frac[2] >= '5'
+		frac = frac[:2]
+		m, err := ParseMoney(whole+"."+frac, currency)
+		if err != nil {
+			return m, err
+		}
+		if roundUp {
+			m.Cents++
+		}
+		if negative {
+			m.Cents = -m.Cents
+		}
+		return m, nil
+	}
+	for len(frac) < 2 {
+		frac += "0"
+	}
+	if whole == "" {
+		whole = "0"
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q", amount)
+	}
+	cents, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q", amount)
+	}
+
+	total := units*100 + cents
+	if negative {
+		total = -total
+	}
+	return Money{Cents: total, Currency: currency}, nil
+}
+
+// isDigits reports whether s is only ASCII digits (true for "")
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseCents parses an integer amount in cents ("1234" = 12.34), as returned by /account/v1/balance
+func ParseCents(cents, currency string) (Money, error) {
+	if strings.TrimSpace(cents) == "" {
+		return Money{Currency: currency}, nil
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(cents), 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid cents %q", cents)
+	}
+	return Money{Cents: value, Currency: currency}, nil
+}
+
+// Add adds an amount of the same currency, a zero amount takes the currency of the other one.
+// Amounts in different currencies can't be added without a rate: other is left out and a warning
+// is logged. Sums over data of any currency convert first (services.ConvertMoney).
+func (m Money) Add(other Money) Money {
+	if m.Cents != 0 && other.Cents != 0 && m.CurrencyCode() != other.CurrencyCode() {
+		slog.Warn("Currency mismatch, amount left out of the sum", "sum", m.String()+" "+m.CurrencyCode(), "amount", other.String()+" "+other.CurrencyCode())
+		return m
+	}
+	if m.Currency == "" || (m.Cents == 0 && other.Cents != 0) {
+		m.Currency = other.Currency
+	}
+	m.Cents += other.Cents
+	return m
+}
+
+func (m Money) Sub(other Money) Money {
+	return m.Add(other.Neg())
+}
+
+func (m Money) Neg() Money {
+	m.Cents = -m.Cents
+	return m
+}
+
+func (m Money) Abs() Money {
+	if m.Cents < 0 {
+		return m.Neg()
+	}
+	return m
+}
+
+// MulRate multiplies by a rate (fees, FX), rounding to the nearest cent
+func (m Money) MulRate(rate float64) Money {
+	m.Cents = int64(math.Round(float64(m.Cents) * rate))
+	return m
+}
+
+// Percent returns m as a percentage of base (0 if base is zero)
+func (m Money) Percent(base Money) float64 {
+	if base.Cents == 0 {
+		return 0
+	}
+	return float64(m.Cents) / float64(base.Cents) * 100
+}
+
+// Float is for ratios and display only, never for arithmetic
+func (m Money) Float() float64 {
+	return float64(m.Cents) / 100
+}
+
+func (m Money) IsZero() bool {
+	return m.Cents == 0
+}
+
+// CurrencyCode returns the currency, defaulting to USD
+func (m Money) CurrencyCode() string {
+	if m.Currency == "" {
+		return "USD"
+	}
+	return m.Currency
+}
+
+// String formats the amount as "-12.34" (no currency)
+func (m Money) String() string {
+	sign := ""
+	cents := m.Cents
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// MarshalJSON uses the DMarket shape: {"amount":"12.34","currency":"USD"}
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.String(), m.CurrencyCode()})
+}
+
+// UnmarshalJSON accepts {"amount":"12.34","currency":"USD"} and plain numbers/strings (dollars)
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*m = Money{}
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '{' {
+		var raw struct {
+			Amount   json.Number `json:"amount"`
+			Currency string      `json:"currency"`
+		}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+		parsed, err := ParseMoney(raw.Amount.String(), raw.Currency)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	}
+
+	// Plain number ("12.34" or 12.34), used by older ledger lines
+	parsed, err := ParseMoney(strings.Trim(string(data), `"`), "USD")
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
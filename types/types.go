@@ -1,5 +1,11 @@
 package types
 
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
 type AccountConfig struct {
 	Label           string `json:"label"`
 	DMarketKey      string `json:"dmarket_key"`
@@ -9,6 +15,120 @@ type AccountConfig struct {
 	AdvancedBalance bool   `json:"advanced_balance"`
 	ProfitPercent   bool   `json:"profit_percent"`
 	IgnoreReleased  bool   `json:"ignore_released"`
+	HoldingTime     bool   `json:"holding_time"` // Show "Held: 12d 4h" on sells
+
+	// Display currency (optional). Rates are units per 1 USD, first configured source wins: url > file > table.
+	DisplayCurrency string             `json:"display_currency"` // e.g. "EUR", empty = as received
+	DisplayMode     string             `json:"display_mode"`     // "alongside" (default) or "instead"
+	FXRates         map[string]float64 `json:"fx_rates"`         // Static table: {"EUR": 0.92}
+	FXFile          string             `json:"fx_file"`          // JSON file with the same shape, re-read on change
+	FXURL           string             `json:"fx_url"`           // HTTP source returning {"rates": {...}}
+	FXCacheMinutes  int                `json:"fx_cache_minutes"` // HTTP cache, default 60
+
+	Notifier     string   `json:"notifier"`      // Name from the global notifiers section, fills token/chat
+	PollInterval Duration `json:"poll_interval"` // Filled from global poll_interval if empty
+	HistoryLimit int      `json:"history_limit"` // Filled from global history_limit if empty
+	DryRun       bool     `json:"-"`             // Print messages instead of sending, don't write the ledger
+
+	// Staleness alert: sent when no poll succeeded for StaleAfter
+	StaleAfter  Duration `json:"stale_after"`          // Filled from global stale_after
+	AlertToken  string   `json:"alert_telegram_token"` // Filled from the owner notifier, else the account's bot
+	AlertChatID string   `json:"alert_chat_id"`        // Filled from the owner notifier, else the account's chat
+
+	// Balance snapshots and alerts (sent to the alert chat)
+	BalanceInterval    Duration `json:"balance_interval"`     // Filled from global balance_interval
+	MinBalance         Money    `json:"min_balance"`          // Alert when the available balance drops below, 0 = off
+	BalanceChangeAlert bool     `json:"balance_change_alert"` // Alert when the balance changes without a matching transaction
+	BalanceTolerance   Money    `json:"balance_tolerance"`    // Unexplained change ignored below this, default 1.00
+
+	ReleaseDigest string `json:"release_digest"` // "HH:MM" UTC of the daily released-trades message, "off" = none
+	StickerPrices string `json:"sticker_prices"` // Price source for the applied stickers' value: "dmarket", empty = off
+	SendImages    bool   `json:"send_images"`    // Post trades as the item's photo with the message as caption
+	Buttons       bool   `json:"buttons"`        // Link buttons (DMarket, CSFloat, inspect) and "Mark cost" under messages
+
+	Rules []Rule `json:"rules"` // Extra notifications for matching transactions
+}
+
+// Rule pings on transactions matching every condition that is set
+type Rule struct {
+	Name string `json:"name"`
+
+	// Conditions
+	Types            []string `json:"types"`              // History types: sell, purchase, target_closed, deposit...
+	Statuses         []string `json:"statuses"`           // success, trade_protected, reverted
+	Item             string   `json:"item"`               // Regexp on the item name, e.g. "(?i)doppler"
+	MinPrice         Money    `json:"min_price"`          // Amount moved at least
+	MaxPrice         Money    `json:"max_price"`          // Amount moved at most
+	MinProfitPercent *float64 `json:"min_profit_percent"` // Sells with a known buy price only
+	MaxProfitPercent *float64 `json:"max_profit_percent"` // e.g. -5 for losses of 5% and more
+	MaxFloat         float64  `json:"max_float"`          // Items with a float below this
+	PaintSeeds       []int    `json:"paint_seeds"`
+
+	// Actions: a Telegram message (to the rule's chat, else the account's) and/or a webhook
+	Notifier       string `json:"notifier"`         // Name from the global notifiers section, fills token/chat
+	TelegramToken  string `json:"telegram_token"`   // Default: the account's bot
+	TelegramChatID string `json:"telegram_chat_id"` // Default: the account's chat
+	Mention        string `json:"mention"`          // e.g. "@trader", put in the first line
+	Pin            bool   `json:"pin"`              // Pin the rule's message (the bot must be allowed to)
+	Webhook        string `json:"webhook"`          // URL receiving the match as JSON (POST)
+}
+
+// SendsMessage tells if the rule posts to Telegram (a webhook-only rule doesn't)
+func (r Rule) SendsMessage() bool {
+	return r.TelegramChatID != "" || r.Mention != "" || r.Pin
+}
+
+// Config is the whole config file: global settings plus accounts
+type Config struct {
+	PollInterval    Duration                  `json:"poll_interval"`    // Default 15s
+	HistoryLimit    int                       `json:"history_limit"`    // Transactions requested per poll, default 50
+	StoragePath     string                    `json:"storage_path"`     // Ledger/state directory, default "data"
+	LogLevel        string                    `json:"log_level"`        // debug, info (default), warn, error
+	LogFormat       string                    `json:"log_format"`       // text (default) or json
+	HTTPAddr        string                    `json:"http_addr"`        // e.g. ":9090" serves /metrics, /healthz, /readyz, empty = off
+	DashboardAddr   string                    `json:"dashboard_addr"`   // e.g. "127.0.0.1:8080" serves the web dashboard, empty = off
+	APITokens       map[string]string         `json:"api_tokens"`       // Consumer name -> token for the REST API on http_addr
+	StaleAfter      Duration                  `json:"stale_after"`      // Alert when an account hasn't polled for this long, default 10m
+	BalanceInterval Duration                  `json:"balance_interval"` // Balance snapshot interval, default 5m
+	ReleaseDigest   string                    `json:"release_digest"`   // Daily released-trades message, "HH:MM" UTC, default "08:30"
+	OwnerNotifier   string                    `json:"owner_notifier"`   // Notifier receiving alerts (default: each account's own chat)
+	Notifiers       map[string]NotifierConfig `json:"notifiers"`
+	Accounts        []AccountConfig           `json:"accounts"`
+}
+
+// NotifierConfig is a named Telegram destination shared by accounts
+type NotifierConfig struct {
+	TelegramToken  string `json:"telegram_token"`
+	TelegramChatID string `json:"telegram_chat_id"`
+}
+
+// Duration reads "15s" / "5m" strings (or plain seconds) from config files
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	switch value := raw.(type) {
+	case float64:
+		*d = Duration(time.Duration(value * float64(time.Second)))
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	case nil:
+		*d = 0
+	default:
+		return fmt.Errorf("invalid duration %v", raw)
+	}
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
 }
 
 type ChatIDConfig struct {
@@ -29,32 +149,30 @@ type Transaction struct {
 		Type  string `json:"type"`
 	} `json:"contractor"`
 	Details struct {
-		SettlementTime int64  `json:"-"`
-		Image          string `json:"-"`
+		SettlementTime int64  `json:"settlementTime"` // Trade protection end, unix seconds
+		Image          string `json:"image"`          // Item picture URL, may be empty
 		ItemID         string `json:"itemId"`
 		Extra          struct {
-			FloatPartValue string  `json:"floatPartValue"`
-			FloatValue     float64 `json:"floatValue"`
-			PaintSeed      *int    `json:"paintSeed"` // to compare nil instead of 0
-			PhaseTitle     string  `json:"phaseTitle"`
+			FloatPartValue string    `json:"floatPartValue"`
+			FloatValue     float64   `json:"floatValue"`
+			PaintSeed      *int      `json:"paintSeed"` // to compare nil instead of 0
+			PhaseTitle     string    `json:"phaseTitle"`
+			Stickers       []Sticker `json:"stickers"`
+			Keychains      []Sticker `json:"keychains"` // Charms
+			NameTag        string    `json:"nameTag"`
+			InspectInGame  string    `json:"inspectInGame"` // steam://rungame/730/... link, may be empty
 		} `json:"extra"`
 	} `json:"details"`
 	Changes []struct {
-		Money struct {
-			Amount   string `json:"amount"`
-			Currency string `json:"currency"`
-		} `json:"money"`
+		Money      Money  `json:"money"`
 		ChangeType string `json:"changeType"`
 	} `json:"changes"`
-	From    string `json:"from"`
-	To      string `json:"to"`
-	Status  string `json:"status"`
-	Balance struct {
-		Amount   string `json:"amount"`
-		Currency string `json:"currency"`
-	} `json:"balance"`
-	UpdatedAt int64 `json:"updatedAt"`
-	CreatedAt int64 `json:"createdAt"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Status    string `json:"status"`
+	Balance   Money  `json:"balance"`
+	UpdatedAt int64  `json:"updatedAt"`
+	CreatedAt int64  `json:"createdAt"`
 }
 
 type TransactionsResponse struct {
@@ -62,11 +180,151 @@ type TransactionsResponse struct {
 	Total   int           `json:"total"`
 }
 
-type CostMap map[string]float64
+// CostEntry is what we paid for an item and when we got it
+type CostEntry struct {
+	Price      Money `json:"price"`
+	AcquiredAt int64 `json:"acquiredAt,omitempty"` // Unix seconds, 0 if unknown
+	Manual     bool  `json:"manual,omitempty"`     // Set by hand, synced buy histories don't replace it
+	MarkedAt   int64 `json:"markedAt,omitempty"`   // Unix seconds the manual price was set, only purchases created after it replace it
+}
+
+type CostMap map[string]CostEntry
+
+// LedgerEntry is one processed transaction as stored in the ledger file
+type LedgerEntry struct {
+	Account    string  `json:"account"`
+	TxID       string  `json:"txId"`
+	Type       string  `json:"type"`
+	Action     string  `json:"action"`
+	Status     string  `json:"status"`
+	ItemID     string  `json:"itemId,omitempty"`
+	Title      string  `json:"title"`
+	Float      float64 `json:"float,omitempty"`
+	PaintSeed  *int    `json:"paintSeed,omitempty"`
+	Amount     Money   `json:"amount"`               // Money moved by the transaction
+	BuyPrice   Money   `json:"buyPrice,omitzero"`    // Sells only, zero if cost basis unknown
+	Profit     Money   `json:"profit,omitzero"`      // Sells only
+	AcquiredAt int64   `json:"acquiredAt,omitempty"` // Sells only, 0 if unknown
+	Balance    Money   `json:"balance"`
+	Time       int64   `json:"time"`                // CreatedAt of the transaction
+	Flow       string  `json:"flow,omitempty"`      // capital or item, empty for trades
+	Capital    Money   `json:"capital,omitzero"`    // Capital only: deposits/refunds positive, withdrawals negative
+	UpdatedAt  int64   `json:"updatedAt,omitempty"` // Last status change
+	ReleaseAt  int64   `json:"releaseAt,omitempty"` // Trade protection end, kept after the release
+}
+
+// ExportRow is one line of the trade ledger export. Sells carry the joined buy leg.
+type ExportRow struct {
+	Date         string  `json:"date"` // RFC3339
+	Account      string  `json:"account"`
+	Source       string  `json:"source"` // dmarket, csfloat
+	TxID         string  `json:"txId"`
+	Type         string  `json:"type"`
+	Status       string  `json:"status"`
+	Item         string  `json:"item"`
+	ItemID       string  `json:"itemId,omitempty"`
+	Float        float64 `json:"float,omitempty"`
+	PaintSeed    *int    `json:"paintSeed,omitempty"`
+	Amount       Money   `json:"amount"` // Signed: negative = money out
+	BuyDate      string  `json:"buyDate,omitempty"`
+	BuyPrice     Money   `json:"buyPrice,omitzero"`
+	SellPrice    Money   `json:"sellPrice,omitzero"`
+	Fee          Money   `json:"fee,omitzero"` // DMarket sells: the tracker's fee estimate (sellFee), not in RealizedGain
+	RealizedGain Money   `json:"realizedGain,omitzero"`
+}
+
+// BackfillSummary is the outcome of rebuilding one account's ledger from history
+type BackfillSummary struct {
+	Label   string
+	Since   int64
+	Stored  int // New or changed ledger entries
+	Sells   int // Successful sells since the start date
+	Matched int // Sells with a reconstructed buy price
+	Profit  Money
+}
+
+// AccountHealth is the polling state of one account, served on /healthz and /readyz
+type AccountHealth struct {
+	Label               string    `json:"label"`
+	StartedAt           time.Time `json:"started_at"`
+	LastPoll            time.Time `json:"last_poll,omitzero"`
+	LastSuccess         time.Time `json:"last_success,omitzero"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	Degraded            bool      `json:"degraded"`
+	Stale               bool      `json:"stale"`
+}
+
+// DashboardAccount is one account's summary on the web dashboard
+type DashboardAccount struct {
+	Label         string `json:"label"`
+	Balance       Money  `json:"balance"` // After the newest ledger transaction
+	LastTx        int64  `json:"lastTx,omitempty"`
+	Transactions  int    `json:"transactions"`
+	Sells         int    `json:"sells"`         // Not reverted
+	SellsWithCost int    `json:"sellsWithCost"` // Sells with a known buy price (cost-basis coverage)
+	Realized      Money  `json:"realized"`      // Profit of the sells with a known buy price
+	NetDeposits   Money  `json:"netDeposits"`   // Capital in minus out (deposits, refunds, withdrawals)
+}
+
+// BalancePoint is the account balance right after one transaction (or at a scheduled snapshot)
+type BalancePoint struct {
+	Time           int64 `json:"time"`
+	Balance        Money `json:"balance"`
+	TradeProtected Money `json:"tradeProtected,omitzero"` // Snapshots only
+}
+
+// DailyPnL is the realized profit of one day (local time)
+type DailyPnL struct {
+	Date   string `json:"date"` // 2006-01-02
+	Profit Money  `json:"profit"`
+	Sells  int    `json:"sells"`
+}
+
+// PeriodPnL is the realized profit of one day, week or month (local time)
+type PeriodPnL struct {
+	Period string `json:"period"` // 2006-01-02, 2006-W01 or 2006-01
+	Profit Money  `json:"profit"`
+	Sells  int    `json:"sells"`
+}
+
+// BalanceSnapshot is one scheduled reading of an account's balance
+type BalanceSnapshot struct {
+	Account        string `json:"account"`
+	Time           int64  `json:"time"`
+	Available      Money  `json:"available"`
+	TradeProtected Money  `json:"tradeProtected"`
+}
+
+// Total is the available plus the trade-protected balance
+func (s BalanceSnapshot) Total() Money {
+	return s.Available.Add(s.TradeProtected)
+}
+
+// CheckResult is one row of the "check" table, each field is "ok", "skipped" or the failure reason
+type CheckResult struct {
+	Label    string
+	DMarket  string
+	CSFloat  string
+	Telegram string
+	Chat     string
+	OK       bool
+}
+
+// HoldingStat summarizes how long capital sat in a group of sold items
+type HoldingStat struct {
+	Key          string
+	Sells        int
+	MedianDays   float64
+	Profit       Money
+	ProfitPerDay Money // Total profit / total days held
+}
 
 type CSFloatTrade struct {
-	ID       string `json:"id"`
-	Contract struct {
+	ID         string `json:"id"`
+	CreatedAt  string `json:"created_at"`  // RFC3339
+	VerifiedAt string `json:"verified_at"` // RFC3339, empty while pending
+	Contract   struct {
 		Price int `json:"price"` // Price is in CENTS (e.g., 100 = $1.00)
 		Item  struct {
 			FloatValue float64 `json:"float_value"`
@@ -77,19 +335,67 @@ type CSFloatTrade struct {
 }
 
 type DMarketInventoryItem struct {
-	ItemID string `json:"itemId"`
-	Extra  struct {
-		FloatValue float64 `json:"floatValue"`
-		PaintSeed  *int    `json:"paintSeed"` // DMarket uses int for seed
+	ItemID    string `json:"itemId"`
+	Title     string `json:"title"`
+	InMarket  bool   `json:"inMarket"`
+	CreatedAt int64  `json:"createdAt"`
+	Image     string `json:"image"`
+	Price     struct {
+		USD string `json:"USD"` // Price is in CENTS
+	} `json:"price"`
+	Extra struct {
+		FloatValue float64   `json:"floatValue"`
+		PaintSeed  *int      `json:"paintSeed"` // DMarket uses int for seed
+		Stickers   []Sticker `json:"stickers"`
+		Keychains  []Sticker `json:"keychains"` // Charms
+		NameTag    string    `json:"nameTag"`
 	} `json:"extra"`
 }
 
+// Sticker is a sticker or charm applied to an item
+type Sticker struct {
+	Name  string  `json:"name"`
+	Slot  *int    `json:"slot"` // Position on the weapon (0-4), nil if unknown
+	Wear  float64 `json:"wear"` // Scrape, 0 = intact
+	Image string  `json:"image"`
+}
+
 // DMarketInventoryResponse represents the API response from /exchange/v1/user/offers
 type DMarketInventoryResponse struct {
 	Objects []DMarketInventoryItem `json:"objects"`
 	Cursor  string                 `json:"cursor"`
 }
 
+// DMarketMarketItemsResponse represents the API response from /exchange/v1/market/items
+type DMarketMarketItemsResponse struct {
+	Objects []DMarketInventoryItem `json:"objects"`
+}
+
+// InventoryReportItem is a single held item valued against its cost basis
+type InventoryReportItem struct {
+	ItemID      string    `json:"itemId"`
+	Title       string    `json:"title"`
+	BuyPrice    Money     `json:"buyPrice,omitzero"`    // Zero if cost basis is unknown
+	ListedPrice Money     `json:"listedPrice,omitzero"` // Zero if not listed for sale
+	MarketPrice Money     `json:"marketPrice,omitzero"` // Lowest market price, zero if unavailable
+	Unrealized  Money     `json:"unrealized,omitzero"`  // Current value minus buy price
+	HeldSince   int64     `json:"heldSince,omitempty"`
+	Stickers    []Sticker `json:"stickers,omitempty"`
+	Keychains   []Sticker `json:"keychains,omitempty"`
+	NameTag     string    `json:"nameTag,omitempty"`
+}
+
+// InventoryReport groups valued items for one account
+type InventoryReport struct {
+	Label           string                `json:"account"`
+	Items           []InventoryReportItem `json:"items"`
+	TotalCost       Money                 `json:"totalCost"`
+	TotalValue      Money                 `json:"totalValue"`
+	TotalUnrealized Money                 `json:"totalUnrealized"`
+	UnknownCost     int                   `json:"unknownCost"`  // Items without cost basis (excluded from totals)
+	UnknownValue    int                   `json:"unknownValue"` // Items with a buy price but no listed or market price (excluded from totals)
+}
+
 // CSFloatResponse represents the list of trades
 type CSFloatResponse struct {
 	Trades []CSFloatTrade `json:"Trades"`
@@ -97,8 +403,20 @@ type CSFloatResponse struct {
 }
 
 type UserBalanceResponse struct {
-	Usd               string `json:"usd"`               // Available
-	UsdTradeProtected string `json:"usdTradeProtected"` // Pending
+	Usd               string `json:"usd"`               // Available, in CENTS
+	UsdTradeProtected string `json:"usdTradeProtected"` // Pending, in CENTS
+}
+
+// Available balance (the endpoint returns cents, unlike /history)
+func (b UserBalanceResponse) Available() Money {
+	m, _ := ParseCents(b.Usd, "USD")
+	return m
+}
+
+// TradeProtected is the pending balance locked until trades settle
+func (b UserBalanceResponse) TradeProtected() Money {
+	m, _ := ParseCents(b.UsdTradeProtected, "USD")
+	return m
 }
 
 type TargetTrade struct {
@@ -106,8 +424,8 @@ type TargetTrade struct {
 	TargetID string `json:"TargetID"`
 	AssetID  string `json:"AssetID"` // This corresponds to ItemID
 	Price    struct {
-		CurrencyCode string  `json:"CurrencyCode"`
-		Amount       float64 `json:"Amount"`
+		CurrencyCode string      `json:"CurrencyCode"`
+		Amount       json.Number `json:"Amount"` // Dollars, kept as text to avoid float rounding
 	} `json:"Price"`
 	Title            string `json:"Title"`
 	ClosedAt         string `json:"ClosedAt"`
@@ -125,4 +443,4 @@ type AttributeKey struct {
 	Phase          string
 	PaintSeed      string
 	FloatPartValue string
-}
\ No newline at end of file
+}